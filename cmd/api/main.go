@@ -1,46 +1,179 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/gofiber/template/html/v2"
 	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
 	"github.com/morgansundqvist/service-composable-commerce/internal/api"
 	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/config"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"github.com/morgansundqvist/service-composable-commerce/internal/lifecycle"
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to a JSON config file, settings can also be given as COMMERCE_* environment variables")
+	flag.Parse()
+
 	logger := adapters.NewLogrusLogger()
-	logger.SetLogLevel("debug")
 
-	db, err := gorm.Open(sqlite.Open("commerce.db"), &gorm.Config{})
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("invalid configuration", map[string]interface{}{
+			"error": err,
+		})
+	}
+	logger.SetLogLevel(cfg.LogLevel)
+
+	// Transactions take the write lock up front so two of them can't deadlock
+	// upgrading their read locks
+	db, err := gorm.Open(sqlite.Open(cfg.DatabasePath+"?_txlock=immediate"), &gorm.Config{TranslateError: true})
 	if err != nil {
 		logger.Fatal("failed to connect to database", map[string]interface{}{
 			"error": err,
 		})
 	}
 
-	productRepository := adapters.NewGormSLProductRepository(db, logger)
-	orderRepository := adapters.NewGormSLOrderRepository(db)
+	// Every repository migrates its own tables
+	var migrationErrs []error
+	productRepository, err := adapters.NewGormSLProductRepository(db, logger)
+	migrationErrs = append(migrationErrs, err)
+	orderRepository, err := adapters.NewGormSLOrderRepository(db)
+	migrationErrs = append(migrationErrs, err)
+	paymentRepository, err := adapters.NewGormSLPaymentRepository(db)
+	migrationErrs = append(migrationErrs, err)
+	inventoryRepository, err := adapters.NewGormSLInventoryRepository(db)
+	migrationErrs = append(migrationErrs, err)
+	apiKeyRepository, err := adapters.NewGormSLAPIKeyRepository(db)
+	migrationErrs = append(migrationErrs, err)
+	customerRepository, err := adapters.NewGormSLCustomerRepository(db)
+	migrationErrs = append(migrationErrs, err)
+	jobRunRepository, err := adapters.NewGormSLJobRunRepository(db)
+	migrationErrs = append(migrationErrs, err)
+	if err := errors.Join(migrationErrs...); err != nil {
+		logger.Fatal("failed to migrate database", map[string]interface{}{
+			"error": err,
+		})
+	}
+	unitOfWork := adapters.NewGormSLUnitOfWork(db)
+	clock := adapters.NewSystemClock()
+	idGenerator := adapters.NewUUIDGenerator()
+
+	productService := application.NewProductService(unitOfWork, productRepository, inventoryRepository, clock, idGenerator, logger)
+	priceCalculator, err := domain.NewPriceCalculator(cfg.VATRatePercent, cfg.Discounts)
+	if err != nil {
+		logger.Fatal("failed to create price calculator", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	paymentProvider, err := newPaymentProvider(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to create payment provider", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	orderService := application.NewOrderService(unitOfWork, orderRepository, productRepository, inventoryRepository, paymentRepository, paymentProvider, priceCalculator, time.Duration(cfg.ReservationTTL), time.Duration(cfg.AbandonedCartAge), clock, idGenerator, logger)
+
+	authService := application.NewAuthService(apiKeyRepository, clock, idGenerator, logger)
 
-	productService := application.NewProductService(productRepository, logger)
-	orderService := application.NewOrderService(orderRepository, logger)
+	// Session tokens survive restarts only when the secret is configured
+	sessionSecret := secretOrRandom(cfg.SessionSecret, "no session secret is configured, using a random secret that invalidates session tokens on restart", logger)
+	tokenSigner := adapters.NewHMACTokenSigner(sessionSecret, clock)
+	sessionService := application.NewSessionService(tokenSigner, time.Duration(cfg.SessionTokenTTL), clock, logger)
+	customerService := application.NewCustomerService(customerRepository, adapters.NewBcryptPasswordHasher(), tokenSigner, time.Duration(cfg.CustomerTokenTTL), clock, idGenerator, logger)
+
+	scheduler := application.NewScheduler(jobRunRepository, clock, idGenerator, logger)
+	jobs := []struct {
+		name     string
+		schedule string
+		run      func(ctx context.Context) error
+	}{
+		{"cart-cleanup", cfg.CartCleanupSchedule, func(ctx context.Context) error {
+			// The counts are logged by the cleanup itself
+			_, err := orderService.RemoveOldCreatedOrders(ctx)
+			return err
+		}},
+		{"reservation-expiry", cfg.ReservationExpirySchedule, orderService.ReleaseExpiredReservations},
+	}
+	for _, job := range jobs {
+		// The schedules were validated with the config
+		schedule, _ := domain.ParseSchedule(job.schedule)
+		err = scheduler.Register(job.name, schedule, time.Duration(cfg.JobJitter), job.run)
+		if err != nil {
+			logger.Fatal("failed to register job", map[string]interface{}{
+				"error": err,
+				"job":   job.name,
+			})
+		}
+	}
 
 	// Setup the template engine
-	engine := html.New("./views", ".html")
+	engine := html.New(cfg.ViewsDir, ".html")
+
+	app := api.SetupRouter(productService, orderService, authService, sessionService, customerService, scheduler, engine, time.Duration(cfg.RequestTimeout), logger)
+
+	manager := lifecycle.NewManager(time.Duration(cfg.ShutdownTimeout), logger)
 
-	app := api.SetupRouter(productService, orderService, engine, logger)
+	manager.Go("http server", func(ctx context.Context) error {
+		return api.Serve(ctx, app, cfg.Address, time.Duration(cfg.ShutdownTimeout))
+	})
 
-	//run delete order job every 5 minutes
-	go func() {
-		for {
-			logger.Info("starting execution of delete old orders job", nil)
-			orderService.RemoveOldCreatedOrders()
-			<-time.After(5 * time.Minute)
+	manager.Go("scheduler", scheduler.Run)
+
+	manager.OnShutdown("database", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
 		}
-	}()
+		return sqlDB.Close()
+	})
 
-	app.Listen(":3000")
+	err = manager.Run(context.Background())
+	if err != nil {
+		logger.Fatal("server did not shut down cleanly", map[string]interface{}{
+			"error": err,
+		})
+	}
+}
+
+// secretOrRandom returns the configured secret, or a random one after logging
+// warning when none is configured.
+func secretOrRandom(configured string, warning string, logger ports.Logger) []byte {
+	if configured != "" {
+		return []byte(configured)
+	}
+
+	logger.Warn(warning, nil)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		logger.Fatal("failed to generate secret", map[string]interface{}{
+			"error": err,
+		})
+	}
+	return secret
+}
+
+// newPaymentProvider creates the payment gateway chosen in the config.
+func newPaymentProvider(cfg *config.Config, logger ports.Logger) (ports.PaymentProvider, error) {
+	switch cfg.PaymentProvider {
+	case config.PaymentProviderFake:
+		// The config only allows the fake gateway in development
+		logger.Warn("using the fake payment provider, payments are not charged", nil)
+		callbackSecret := secretOrRandom(cfg.PaymentCallbackSecret, "no payment callback secret is configured, using a random secret that invalidates payment callbacks on restart", logger)
+		return adapters.NewFakePaymentProvider(callbackSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.PaymentProvider)
+	}
 }
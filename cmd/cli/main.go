@@ -1,5 +1,112 @@
 package main
 
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <command>\n\nCommands:\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "  cleanup-orphans           delete order lines, content lines, status transitions, order numbers, payments and stock reservations of deleted orders\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  clear-duplicate-sessions  keep the oldest order of sessions with several orders and detach the others, needed once before the server can make session IDs unique\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  create-api-key            create an API key named -name with the comma separated -roles and print it\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
 func main() {
+	dbPath := flag.String("db", "commerce.db", "path to the SQLite database")
+	name := flag.String("name", "", "name of the API key, recorded as the actor of the changes made with it")
+	roles := flag.String("roles", string(domain.RoleAdmin), "comma separated roles of the API key")
+	flag.Usage = usage
+	flag.Parse()
+
+	logger := adapters.NewLogrusLogger()
+	logger.SetLogLevel("info")
+
+	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{TranslateError: true})
+	if err != nil {
+		logger.Fatal("failed to connect to database", map[string]interface{}{
+			"error": err,
+		})
+	}
+
+	switch flag.Arg(0) {
+	case "cleanup-orphans":
+		// Orphaned payments and stock reservations are deleted too, so their
+		// tables have to exist
+		orderRepository, err := adapters.NewGormSLOrderRepository(db)
+		if err == nil {
+			_, err = adapters.NewGormSLPaymentRepository(db)
+		}
+		if err == nil {
+			_, err = adapters.NewGormSLInventoryRepository(db)
+		}
+		if err != nil {
+			logger.Fatal("failed to migrate database", map[string]interface{}{
+				"error": err,
+			})
+		}
+
+		deleted, err := orderRepository.DeleteOrphanedOrderRows(context.Background())
+		if err != nil {
+			logger.Fatal("failed to delete orphaned order rows", map[string]interface{}{
+				"error": err,
+			})
+		}
+
+		logger.Info("deleted orphaned order rows", map[string]interface{}{
+			"order_lines":        deleted.OrderLines,
+			"content_lines":      deleted.ContentLines,
+			"status_transitions": deleted.StatusTransitions,
+			"order_numbers":      deleted.OrderNumbers,
+			"payments":           deleted.Payments,
+			"stock_reservations": deleted.StockReservations,
+		})
+	case "clear-duplicate-sessions":
+		cleared, err := adapters.ClearDuplicateSessionIds(context.Background(), db)
+		if err != nil {
+			logger.Fatal("failed to clear duplicate session IDs", map[string]interface{}{
+				"error": err,
+			})
+		}
+
+		logger.Info("cleared duplicate session IDs", map[string]interface{}{
+			"orders": cleared,
+		})
+	case "create-api-key":
+		apiKeyRepository, err := adapters.NewGormSLAPIKeyRepository(db)
+		if err != nil {
+			logger.Fatal("failed to migrate database", map[string]interface{}{
+				"error": err,
+			})
+		}
+		authService := application.NewAuthService(apiKeyRepository, adapters.NewSystemClock(), adapters.NewUUIDGenerator(), logger)
+
+		input := domain.CreateAPIKeyInput{Name: *name}
+		for _, role := range strings.Split(*roles, ",") {
+			input.Roles = append(input.Roles, domain.Role(strings.TrimSpace(role)))
+		}
+
+		created, err := authService.CreateAPIKey(context.Background(), input)
+		if err != nil {
+			logger.Fatal("failed to create API key", map[string]interface{}{
+				"error": err,
+			})
+		}
 
+		// The key is not stored, so this is the only time it can be seen
+		fmt.Println(created.Key)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 }
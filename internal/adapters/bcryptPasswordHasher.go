@@ -0,0 +1,32 @@
+package adapters
+
+import (
+	"errors"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type BcryptPasswordHasher struct {
+	cost int
+}
+
+func NewBcryptPasswordHasher() *BcryptPasswordHasher {
+	return &BcryptPasswordHasher{cost: bcrypt.DefaultCost}
+}
+
+func (h *BcryptPasswordHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptPasswordHasher) Compare(hash string, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return domain.ErrInvalidCredentials
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package adapters
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a clock that only moves when told to, for tests and local
+// runs that depend on the passing of time.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeClockWaiter
+}
+
+// fakeClockWaiter is a channel returned by After, waiting for the clock to
+// reach at.
+type fakeClockWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time once the clock has been
+// moved forward by at least d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	waiter := fakeClockWaiter{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		waiter.c <- c.now
+		return waiter.c
+	}
+	c.waiters = append(c.waiters, waiter)
+	return waiter.c
+}
+
+// Set moves the clock to the given time.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	c.fireWaiters()
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fireWaiters()
+}
+
+// fireWaiters sends the time to the waiters the clock has reached. The caller
+// must hold the lock.
+func (c *FakeClock) fireWaiters() {
+	waiting := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.at.After(c.now) {
+			waiting = append(waiting, waiter)
+			continue
+		}
+		waiter.c <- c.now
+	}
+	c.waiters = waiting
+}
@@ -0,0 +1,35 @@
+package adapters
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAfterFiresWhenTheClockReachesIt(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	after := clock.After(time.Minute)
+	clock.Advance(59 * time.Second)
+	select {
+	case <-after:
+		t.Fatal("fired before the minute had passed")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case at := <-after:
+		if !at.Equal(start.Add(time.Minute)) {
+			t.Fatalf("fired at %v, want %v", at, start.Add(time.Minute))
+		}
+	default:
+		t.Fatal("did not fire once the minute had passed")
+	}
+
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatal("a zero duration did not fire at once")
+	}
+}
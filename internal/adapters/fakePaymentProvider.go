@@ -0,0 +1,218 @@
+package adapters
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// FakeSignatureHeader carries the signature of a fake gateway callback, the
+// hex encoded HMAC-SHA256 of the body under the callback secret.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakePaymentProvider is an in-process payment gateway for tests and local
+// development. Nothing leaves the process. A payment is completed by posting
+// a callback built with Callback to the payment callback endpoint, e.g.
+//
+//	{"reference": "fake_3f2a...", "amount": 12000, "status": "authorized"}
+//
+// signed in the X-Fake-Signature header, which can be computed with
+//
+//	printf '%s' "$BODY" | openssl dgst -sha256 -hmac "$SECRET" -hex
+//
+// Signed callbacks carry the amount, so payments started before a restart
+// can still be completed.
+type FakePaymentProvider struct {
+	secret []byte
+
+	mu      sync.Mutex
+	intents map[string]*fakePaymentIntent
+	// idempotencyKeys holds the keys of the captures, voids and refunds done
+	idempotencyKeys map[string]bool
+}
+
+type fakePaymentIntent struct {
+	amount     int
+	authorized bool
+	voided     bool
+	captured   int
+	refunded   int
+}
+
+type fakePaymentCallback struct {
+	Reference string `json:"reference"`
+	Amount    int    `json:"amount"`
+	Status    string `json:"status"`
+}
+
+const (
+	fakeCallbackStatusAuthorized = "authorized"
+	fakeCallbackStatusDeclined   = "declined"
+)
+
+// NewFakePaymentProvider returns a fake gateway whose callbacks are signed
+// with secret.
+func NewFakePaymentProvider(secret []byte) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		secret:          secret,
+		intents:         map[string]*fakePaymentIntent{},
+		idempotencyKeys: map[string]bool{},
+	}
+}
+
+// Callback returns the body and headers the fake gateway would send when the
+// payment with the given reference and amount is authorized or declined.
+func (p *FakePaymentProvider) Callback(reference string, amount int, authorized bool) ([]byte, map[string]string) {
+	status := fakeCallbackStatusDeclined
+	if authorized {
+		status = fakeCallbackStatusAuthorized
+	}
+	body, _ := json.Marshal(fakePaymentCallback{Reference: reference, Amount: amount, Status: status})
+	return body, map[string]string{FakeSignatureHeader: hex.EncodeToString(p.sign(body))}
+}
+
+func (p *FakePaymentProvider) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func (p *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakePaymentProvider) CreatePaymentIntent(ctx context.Context, order *domain.Order, amount int, currency string) (*domain.PaymentIntent, error) {
+	// References are random so that they don't repeat across restarts
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate payment reference: %w", err)
+	}
+	reference := "fake_" + hex.EncodeToString(random)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.intents[reference] = &fakePaymentIntent{amount: amount}
+
+	return &domain.PaymentIntent{Reference: reference}, nil
+}
+
+func (p *FakePaymentProvider) Capture(ctx context.Context, reference string, amount int, idempotencyKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idempotencyKeys[idempotencyKey] {
+		return nil
+	}
+	intent, ok := p.intents[reference]
+	if !ok {
+		return domain.Errorf(domain.ErrNotFound, "unknown payment reference %s", reference)
+	}
+	if !intent.authorized || intent.voided {
+		return domain.NewError(domain.ErrConflict, "payment is not authorized")
+	}
+	if intent.captured+amount > intent.amount {
+		return domain.NewError(domain.ErrConflict, "capture amount exceeds authorized amount")
+	}
+	intent.captured += amount
+	p.idempotencyKeys[idempotencyKey] = true
+
+	return nil
+}
+
+func (p *FakePaymentProvider) Void(ctx context.Context, reference string, idempotencyKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idempotencyKeys[idempotencyKey] {
+		return nil
+	}
+	intent, ok := p.intents[reference]
+	if !ok {
+		return domain.Errorf(domain.ErrNotFound, "unknown payment reference %s", reference)
+	}
+	if intent.captured > 0 {
+		return domain.NewError(domain.ErrConflict, "payment is already captured")
+	}
+	intent.voided = true
+	p.idempotencyKeys[idempotencyKey] = true
+
+	return nil
+}
+
+func (p *FakePaymentProvider) Refund(ctx context.Context, reference string, amount int, idempotencyKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idempotencyKeys[idempotencyKey] {
+		return nil
+	}
+	intent, ok := p.intents[reference]
+	if !ok {
+		return domain.Errorf(domain.ErrNotFound, "unknown payment reference %s", reference)
+	}
+	if intent.refunded+amount > intent.captured {
+		return domain.NewError(domain.ErrConflict, "refund amount exceeds captured amount")
+	}
+	intent.refunded += amount
+	p.idempotencyKeys[idempotencyKey] = true
+
+	return nil
+}
+
+func (p *FakePaymentProvider) HandleCallback(ctx context.Context, body []byte, headers map[string]string) (*domain.PaymentCallback, error) {
+	signature, err := hex.DecodeString(headerValue(headers, FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.sign(body)) {
+		return nil, domain.NewError(domain.ErrUnauthenticated, "invalid callback signature")
+	}
+
+	var callback fakePaymentCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return nil, domain.Errorf(domain.ErrInvalidInput, "invalid callback body: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[callback.Reference]
+	if !ok {
+		// The intent was created before a restart, the signature vouches for
+		// the amount
+		intent = &fakePaymentIntent{amount: callback.Amount}
+		p.intents[callback.Reference] = intent
+	}
+	if callback.Amount != intent.amount {
+		return nil, domain.Errorf(domain.ErrInvalidInput, "callback amount %d does not match payment amount %d", callback.Amount, intent.amount)
+	}
+
+	switch callback.Status {
+	case fakeCallbackStatusAuthorized:
+		intent.authorized = true
+	case fakeCallbackStatusDeclined:
+	default:
+		return nil, domain.Errorf(domain.ErrInvalidInput, "unknown callback status %q", callback.Status)
+	}
+
+	return &domain.PaymentCallback{
+		Reference:  callback.Reference,
+		Authorized: callback.Status == fakeCallbackStatusAuthorized,
+	}, nil
+}
+
+// headerValue looks up a header regardless of the case of its name.
+func headerValue(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+func TestFakePaymentProviderCallbackReportsItsOwnStatus(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakePaymentProvider([]byte("test-callback-secret-of-32-bytes"))
+	intent, err := provider.CreatePaymentIntent(ctx, nil, 5000, domain.CurrencySEK)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, authorized := range []bool{true, false} {
+		body, headers := provider.Callback(intent.Reference, 5000, authorized)
+		callback, err := provider.HandleCallback(ctx, body, headers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if callback.Authorized != authorized {
+			t.Errorf("callback authorized = %t, want %t", callback.Authorized, authorized)
+		}
+	}
+}
+
+func TestFakePaymentProviderIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakePaymentProvider([]byte("test-callback-secret-of-32-bytes"))
+	intent, err := provider.CreatePaymentIntent(ctx, nil, 5000, domain.CurrencySEK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, headers := provider.Callback(intent.Reference, 5000, true)
+	if _, err := provider.HandleCallback(ctx, body, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := provider.Capture(ctx, intent.Reference, 5000, "capture"); err != nil {
+			t.Fatal(err)
+		}
+		if err := provider.Refund(ctx, intent.Reference, 5000, "refund"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new key is a new refund, of money already refunded
+	err = provider.Refund(ctx, intent.Reference, 5000, "second refund")
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("second refund: error = %v, want %v", err, domain.ErrConflict)
+	}
+}
@@ -0,0 +1,100 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DBAPIKey struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key"`
+	Name    string
+	KeyHash string `gorm:"uniqueIndex"`
+	// Roles are stored comma separated
+	Roles           string
+	CreatedDateTime time.Time
+}
+
+type GormSLAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSLAPIKeyRepository(db *gorm.DB) (*GormSLAPIKeyRepository, error) {
+	if err := db.AutoMigrate(&DBAPIKey{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate API key tables: %w", err)
+	}
+	return &GormSLAPIKeyRepository{db: db}, nil
+}
+
+func toDBAPIKey(apiKey *domain.APIKey) *DBAPIKey {
+	roles := make([]string, len(apiKey.Roles))
+	for i, role := range apiKey.Roles {
+		roles[i] = string(role)
+	}
+
+	return &DBAPIKey{
+		ID:              apiKey.ID,
+		Name:            apiKey.Name,
+		KeyHash:         apiKey.KeyHash,
+		Roles:           strings.Join(roles, ","),
+		CreatedDateTime: apiKey.CreatedDateTime,
+	}
+}
+
+func toDomainAPIKey(dbAPIKey *DBAPIKey) *domain.APIKey {
+	var roles []domain.Role
+	for _, role := range strings.Split(dbAPIKey.Roles, ",") {
+		if role != "" {
+			roles = append(roles, domain.Role(role))
+		}
+	}
+
+	return &domain.APIKey{
+		ID:              dbAPIKey.ID,
+		Name:            dbAPIKey.Name,
+		KeyHash:         dbAPIKey.KeyHash,
+		Roles:           roles,
+		CreatedDateTime: dbAPIKey.CreatedDateTime,
+	}
+}
+
+func (r *GormSLAPIKeyRepository) CreateAPIKey(ctx context.Context, apiKey *domain.APIKey) error {
+	return r.db.WithContext(ctx).Create(toDBAPIKey(apiKey)).Error
+}
+
+func (r *GormSLAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	var dbAPIKey DBAPIKey
+	if err := r.db.WithContext(ctx).Where("key_hash = ?", keyHash).First(&dbAPIKey).Error; err != nil {
+		return nil, translateError(err, domain.ErrAPIKeyNotFound, nil)
+	}
+	return toDomainAPIKey(&dbAPIKey), nil
+}
+
+func (r *GormSLAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	var dbAPIKeys []DBAPIKey
+	if err := r.db.WithContext(ctx).Order("created_date_time").Find(&dbAPIKeys).Error; err != nil {
+		return nil, err
+	}
+
+	apiKeys := make([]*domain.APIKey, len(dbAPIKeys))
+	for i := range dbAPIKeys {
+		apiKeys[i] = toDomainAPIKey(&dbAPIKeys[i])
+	}
+	return apiKeys, nil
+}
+
+func (r *GormSLAPIKeyRepository) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&DBAPIKey{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
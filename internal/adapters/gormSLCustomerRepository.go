@@ -0,0 +1,140 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DBCustomer struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key"`
+	Email           string    `gorm:"uniqueIndex"`
+	Name            string
+	PasswordHash    string
+	CreatedDateTime time.Time
+}
+
+type DBAddress struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key"`
+	CustomerID      uuid.UUID `gorm:"type:uuid;index"`
+	Name            string
+	Address         string
+	ZipCode         string
+	City            string
+	CompanyName     string
+	CreatedDateTime time.Time
+}
+
+type GormSLCustomerRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSLCustomerRepository(db *gorm.DB) (*GormSLCustomerRepository, error) {
+	if err := db.AutoMigrate(&DBCustomer{}, &DBAddress{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate customer tables: %w", err)
+	}
+	return &GormSLCustomerRepository{db: db}, nil
+}
+
+func toDBCustomer(customer *domain.Customer) *DBCustomer {
+	return &DBCustomer{
+		ID:              customer.ID,
+		Email:           customer.Email,
+		Name:            customer.Name,
+		PasswordHash:    customer.PasswordHash,
+		CreatedDateTime: customer.CreatedDateTime,
+	}
+}
+
+func toDomainCustomer(dbCustomer *DBCustomer) *domain.Customer {
+	return &domain.Customer{
+		ID:              dbCustomer.ID,
+		Email:           dbCustomer.Email,
+		Name:            dbCustomer.Name,
+		PasswordHash:    dbCustomer.PasswordHash,
+		CreatedDateTime: dbCustomer.CreatedDateTime,
+	}
+}
+
+func toDBAddress(address *domain.Address) *DBAddress {
+	return &DBAddress{
+		ID:              address.ID,
+		CustomerID:      address.CustomerID,
+		Name:            address.Name,
+		Address:         address.Address,
+		ZipCode:         address.ZipCode,
+		City:            address.City,
+		CompanyName:     address.CompanyName,
+		CreatedDateTime: address.CreatedDateTime,
+	}
+}
+
+func toDomainAddress(dbAddress *DBAddress) *domain.Address {
+	return &domain.Address{
+		ID:              dbAddress.ID,
+		CustomerID:      dbAddress.CustomerID,
+		Name:            dbAddress.Name,
+		Address:         dbAddress.Address,
+		ZipCode:         dbAddress.ZipCode,
+		City:            dbAddress.City,
+		CompanyName:     dbAddress.CompanyName,
+		CreatedDateTime: dbAddress.CreatedDateTime,
+	}
+}
+
+func (r *GormSLCustomerRepository) CreateCustomer(ctx context.Context, customer *domain.Customer) error {
+	return translateError(r.db.WithContext(ctx).Create(toDBCustomer(customer)).Error, nil, domain.ErrEmailTaken)
+}
+
+func (r *GormSLCustomerRepository) GetCustomerById(ctx context.Context, id uuid.UUID) (*domain.Customer, error) {
+	var dbCustomer DBCustomer
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&dbCustomer).Error; err != nil {
+		return nil, translateError(err, domain.ErrCustomerNotFound, nil)
+	}
+	return toDomainCustomer(&dbCustomer), nil
+}
+
+func (r *GormSLCustomerRepository) GetCustomerByEmail(ctx context.Context, email string) (*domain.Customer, error) {
+	var dbCustomer DBCustomer
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&dbCustomer).Error; err != nil {
+		return nil, translateError(err, domain.ErrCustomerNotFound, nil)
+	}
+	return toDomainCustomer(&dbCustomer), nil
+}
+
+func (r *GormSLCustomerRepository) CreateAddress(ctx context.Context, address *domain.Address) error {
+	return r.db.WithContext(ctx).Create(toDBAddress(address)).Error
+}
+
+func (r *GormSLCustomerRepository) UpdateAddress(ctx context.Context, address *domain.Address) error {
+	return r.db.WithContext(ctx).Save(toDBAddress(address)).Error
+}
+
+func (r *GormSLCustomerRepository) GetAddressById(ctx context.Context, id uuid.UUID) (*domain.Address, error) {
+	var dbAddress DBAddress
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&dbAddress).Error; err != nil {
+		return nil, translateError(err, domain.ErrAddressNotFound, nil)
+	}
+	return toDomainAddress(&dbAddress), nil
+}
+
+func (r *GormSLCustomerRepository) GetAddressesByCustomerId(ctx context.Context, customerID uuid.UUID) ([]*domain.Address, error) {
+	var dbAddresses []DBAddress
+	if err := r.db.WithContext(ctx).Where("customer_id = ?", customerID).Order("created_date_time").Find(&dbAddresses).Error; err != nil {
+		return nil, err
+	}
+
+	addresses := make([]*domain.Address, len(dbAddresses))
+	for i := range dbAddresses {
+		addresses[i] = toDomainAddress(&dbAddresses[i])
+	}
+	return addresses, nil
+}
+
+func (r *GormSLCustomerRepository) DeleteAddress(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&DBAddress{}, id).Error
+}
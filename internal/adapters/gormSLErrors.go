@@ -0,0 +1,21 @@
+package adapters
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// translateError maps GORM errors onto domain errors. notFound is returned in
+// place of gorm.ErrRecordNotFound and duplicate, when set, in place of
+// gorm.ErrDuplicatedKey. Duplicate keys are only reported as
+// gorm.ErrDuplicatedKey when the database is opened with TranslateError.
+func translateError(err error, notFound error, duplicate error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	if duplicate != nil && errors.Is(err, gorm.ErrDuplicatedKey) {
+		return duplicate
+	}
+	return err
+}
@@ -0,0 +1,242 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DBStockLevel struct {
+	ProductID       uuid.UUID `gorm:"type:uuid;primary_key"`
+	Quantity        int
+	UpdatedDateTime time.Time
+}
+
+type DBStockReservation struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key"`
+	OrderID         uuid.UUID `gorm:"index"`
+	OrderLineID     uuid.UUID `gorm:"index"`
+	ProductID       uuid.UUID `gorm:"index"`
+	Quantity        int
+	ExpiresDateTime time.Time `gorm:"index"`
+}
+
+type GormSLInventoryRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSLInventoryRepository(db *gorm.DB) (*GormSLInventoryRepository, error) {
+	if err := db.AutoMigrate(&DBStockLevel{}, &DBStockReservation{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate inventory tables: %w", err)
+	}
+	return &GormSLInventoryRepository{db: db}, nil
+}
+
+func toDBStockLevel(stockLevel *domain.StockLevel) *DBStockLevel {
+	return &DBStockLevel{
+		ProductID:       stockLevel.ProductID,
+		Quantity:        stockLevel.Quantity,
+		UpdatedDateTime: stockLevel.UpdatedDateTime,
+	}
+}
+
+func toDomainStockLevel(dbStockLevel *DBStockLevel) *domain.StockLevel {
+	return &domain.StockLevel{
+		ProductID:       dbStockLevel.ProductID,
+		Quantity:        dbStockLevel.Quantity,
+		UpdatedDateTime: dbStockLevel.UpdatedDateTime,
+	}
+}
+
+func (r *GormSLInventoryRepository) SetStockLevel(ctx context.Context, stockLevel *domain.StockLevel) error {
+	dbStockLevel := toDBStockLevel(stockLevel)
+	return r.db.WithContext(ctx).Save(dbStockLevel).Error
+}
+
+func (r *GormSLInventoryRepository) DeleteStockLevel(ctx context.Context, productID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&DBStockLevel{}, productID).Error
+}
+
+func (r *GormSLInventoryRepository) GetStockLevels(ctx context.Context, productIDs []uuid.UUID) ([]domain.StockLevel, error) {
+	if len(productIDs) == 0 {
+		return []domain.StockLevel{}, nil
+	}
+
+	var dbStockLevels []DBStockLevel
+	if err := r.db.WithContext(ctx).Where("product_id IN ?", productIDs).Find(&dbStockLevels).Error; err != nil {
+		return nil, err
+	}
+	stockLevels := make([]domain.StockLevel, len(dbStockLevels))
+	for i, dbStockLevel := range dbStockLevels {
+		stockLevels[i] = *toDomainStockLevel(&dbStockLevel)
+	}
+	return stockLevels, nil
+}
+
+func toDBStockReservation(reservation *domain.StockReservation) *DBStockReservation {
+	return &DBStockReservation{
+		ID:              reservation.ID,
+		OrderID:         reservation.OrderID,
+		OrderLineID:     reservation.OrderLineID,
+		ProductID:       reservation.ProductID,
+		Quantity:        reservation.Quantity,
+		ExpiresDateTime: reservation.ExpiresDateTime,
+	}
+}
+
+func (r *GormSLInventoryRepository) GetReservedQuantities(ctx context.Context, productIDs []uuid.UUID, at time.Time) (map[uuid.UUID]int, error) {
+	reserved := make(map[uuid.UUID]int)
+	if len(productIDs) == 0 {
+		return reserved, nil
+	}
+
+	var rows []struct {
+		ProductID uuid.UUID
+		Quantity  int
+	}
+	err := r.db.WithContext(ctx).Model(&DBStockReservation{}).
+		Select("product_id, SUM(quantity) AS quantity").
+		Where("product_id IN ? AND expires_date_time > ?", productIDs, at).
+		Group("product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		reserved[row.ProductID] = row.Quantity
+	}
+	return reserved, nil
+}
+
+// availableStock returns the stock of a product that is not held by active
+// reservations, and whether the product is tracked at all.
+func availableStock(tx *gorm.DB, productID uuid.UUID, at time.Time) (int, bool, error) {
+	var dbStockLevels []DBStockLevel
+	if err := tx.Where("product_id = ?", productID).Limit(1).Find(&dbStockLevels).Error; err != nil {
+		return 0, false, err
+	}
+	if len(dbStockLevels) == 0 {
+		return 0, false, nil
+	}
+
+	var reserved int
+	err := tx.Model(&DBStockReservation{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("product_id = ? AND expires_date_time > ?", productID, at).
+		Scan(&reserved).Error
+	if err != nil {
+		return 0, false, err
+	}
+
+	return dbStockLevels[0].Quantity - reserved, true, nil
+}
+
+func (r *GormSLInventoryRepository) ReserveStock(ctx context.Context, orderLineID uuid.UUID, reservations []*domain.StockReservation, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_line_id = ?", orderLineID).Delete(&DBStockReservation{}).Error; err != nil {
+			return err
+		}
+
+		for _, reservation := range reservations {
+			available, tracked, err := availableStock(tx, reservation.ProductID, at)
+			if err != nil {
+				return err
+			}
+			if !tracked {
+				continue
+			}
+			if available < reservation.Quantity {
+				return domain.InsufficientStockError(reservation.ProductID, reservation.Quantity, available)
+			}
+
+			if err := tx.Create(toDBStockReservation(reservation)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *GormSLInventoryRepository) ExtendReservationsByOrderId(ctx context.Context, orderID uuid.UUID, at time.Time, expires time.Time) error {
+	return r.db.WithContext(ctx).Model(&DBStockReservation{}).
+		Where("order_id = ? AND expires_date_time > ?", orderID, at).
+		Update("expires_date_time", expires).Error
+}
+
+func (r *GormSLInventoryRepository) GetReservedOrderLineIds(ctx context.Context, orderID uuid.UUID, at time.Time) ([]uuid.UUID, error) {
+	var orderLineIDs []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&DBStockReservation{}).
+		Where("order_id = ? AND expires_date_time > ?", orderID, at).
+		Distinct().
+		Pluck("order_line_id", &orderLineIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return orderLineIDs, nil
+}
+
+func (r *GormSLInventoryRepository) DeleteReservationsByOrderLineId(ctx context.Context, orderLineID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("order_line_id = ?", orderLineID).Delete(&DBStockReservation{}).Error
+}
+
+func (r *GormSLInventoryRepository) DeleteReservationsByOrderId(ctx context.Context, orderID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("order_id = ?", orderID).Delete(&DBStockReservation{}).Error
+}
+
+func (r *GormSLInventoryRepository) DeleteExpiredReservations(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_date_time <= ?", before).Delete(&DBStockReservation{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *GormSLInventoryRepository) ConsumeStock(ctx context.Context, orderID uuid.UUID, quantities map[uuid.UUID]int, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ?", orderID).Delete(&DBStockReservation{}).Error; err != nil {
+			return err
+		}
+
+		for productID, quantity := range quantities {
+			available, tracked, err := availableStock(tx, productID, at)
+			if err != nil {
+				return err
+			}
+			if !tracked {
+				continue
+			}
+			if available < quantity {
+				return domain.InsufficientStockError(productID, quantity, available)
+			}
+
+			err = tx.Model(&DBStockLevel{}).
+				Where("product_id = ?", productID).
+				Updates(map[string]interface{}{
+					"quantity":          gorm.Expr("quantity - ?", quantity),
+					"updated_date_time": at,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *GormSLInventoryRepository) ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for productID, quantity := range quantities {
+			err := tx.Model(&DBStockLevel{}).
+				Where("product_id = ?", productID).
+				Updates(map[string]interface{}{
+					"quantity":          gorm.Expr("quantity + ?", quantity),
+					"updated_date_time": at,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
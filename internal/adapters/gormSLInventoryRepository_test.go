@@ -0,0 +1,68 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+func TestDeleteExpiredReservations(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		elapsed     time.Duration
+		wantDeleted int64
+		wantLeft    int
+	}{
+		{name: "none expired", elapsed: 0, wantDeleted: 0, wantLeft: 3},
+		{name: "expiring now", elapsed: 10 * time.Minute, wantDeleted: 1, wantLeft: 2},
+		{name: "some expired", elapsed: 15 * time.Minute, wantDeleted: 1, wantLeft: 2},
+		{name: "all expired", elapsed: time.Hour, wantDeleted: 3, wantLeft: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clock := NewFakeClock(start)
+			idGenerator := NewSequentialIDGenerator()
+			repository, err := NewGormSLInventoryRepository(newTestDB(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Only products with a stock level are reserved
+			productID := idGenerator.NewID()
+			if err := repository.SetStockLevel(ctx, &domain.StockLevel{ProductID: productID, Quantity: 10, UpdatedDateTime: clock.Now()}); err != nil {
+				t.Fatal(err)
+			}
+			for _, ttl := range []time.Duration{10 * time.Minute, 20 * time.Minute, 30 * time.Minute} {
+				orderLine := &domain.OrderLine{ID: idGenerator.NewID(), OrderID: idGenerator.NewID(), ProductID: productID, Quantity: 1}
+				reservations := domain.CreateStockReservations(idGenerator.NewID, orderLine, nil, clock.Now().Add(ttl))
+				if err := repository.ReserveStock(ctx, orderLine.ID, reservations, clock.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			clock.Advance(tt.elapsed)
+			deleted, err := repository.DeleteExpiredReservations(ctx, clock.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleted %d reservations, want %d", deleted, tt.wantDeleted)
+			}
+
+			reserved, err := repository.GetReservedQuantities(ctx, []uuid.UUID{productID}, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reserved[productID] != tt.wantLeft {
+				t.Errorf("%d units reserved, want %d", reserved[productID], tt.wantLeft)
+			}
+		})
+	}
+}
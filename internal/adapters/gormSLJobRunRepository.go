@@ -0,0 +1,85 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DBJobRun struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key"`
+	JobName          string    `gorm:"index:idx_job_run_job_name_started"`
+	Trigger          string
+	Status           string
+	Error            string
+	StartedDateTime  time.Time `gorm:"index:idx_job_run_job_name_started"`
+	FinishedDateTime time.Time
+	DurationMs       int64
+}
+
+type GormSLJobRunRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSLJobRunRepository(db *gorm.DB) (*GormSLJobRunRepository, error) {
+	if err := db.AutoMigrate(&DBJobRun{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate job run tables: %w", err)
+	}
+	return &GormSLJobRunRepository{db: db}, nil
+}
+
+func toDBJobRun(run *domain.JobRun) *DBJobRun {
+	return &DBJobRun{
+		ID:               run.ID,
+		JobName:          run.JobName,
+		Trigger:          string(run.Trigger),
+		Status:           string(run.Status),
+		Error:            run.Error,
+		StartedDateTime:  run.StartedDateTime,
+		FinishedDateTime: run.FinishedDateTime,
+		DurationMs:       run.DurationMs,
+	}
+}
+
+func toDomainJobRun(dbRun *DBJobRun) *domain.JobRun {
+	return &domain.JobRun{
+		ID:               dbRun.ID,
+		JobName:          dbRun.JobName,
+		Trigger:          domain.JobTrigger(dbRun.Trigger),
+		Status:           domain.JobRunStatus(dbRun.Status),
+		Error:            dbRun.Error,
+		StartedDateTime:  dbRun.StartedDateTime,
+		FinishedDateTime: dbRun.FinishedDateTime,
+		DurationMs:       dbRun.DurationMs,
+	}
+}
+
+func (r *GormSLJobRunRepository) CreateJobRun(ctx context.Context, run *domain.JobRun) error {
+	return r.db.WithContext(ctx).Create(toDBJobRun(run)).Error
+}
+
+func (r *GormSLJobRunRepository) ListJobRuns(ctx context.Context, jobName string, page domain.Page) ([]*domain.JobRun, int64, error) {
+	db := r.db.WithContext(ctx).Model(&DBJobRun{}).Where("job_name = ?", jobName)
+
+	var total int64
+	err := db.Session(&gorm.Session{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dbRuns []DBJobRun
+	err = db.Order("started_date_time desc").Offset(page.Offset).Limit(page.Limit).Find(&dbRuns).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	runs := make([]*domain.JobRun, len(dbRuns))
+	for i := range dbRuns {
+		runs[i] = toDomainJobRun(&dbRuns[i])
+	}
+	return runs, total, nil
+}
@@ -1,6 +1,10 @@
 package adapters
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,79 +13,172 @@ import (
 )
 
 type DBOrder struct {
-	ID              uuid.UUID `gorm:"type:uuid;primary_key"`
-	SessionId       string
-	Email           string
-	Name            string
-	Address         string
-	ZipCode         string
-	City            string
-	CompanyName     string
-	Status          string
-	CreatedDateTime time.Time
+	ID                   uuid.UUID  `gorm:"type:uuid;primary_key"`
+	SessionId            string     `gorm:"uniqueIndex:idx_db_orders_session_id,where:session_id <> ''"` // empty for orders created by staff
+	CustomerID           *uuid.UUID `gorm:"type:uuid;index"`
+	Email                string
+	Name                 string
+	Address              string
+	ZipCode              string
+	City                 string
+	CompanyName          string
+	OrderNumber          string    `gorm:"index"`
+	Status               string    `gorm:"index"`
+	CreatedDateTime      time.Time `gorm:"index"`
+	LastActivityDateTime time.Time `gorm:"index"`
+	Subtotal             int
+	Discount             int
+	VAT                  int
+	Total                int
 }
 
 type DBOrderLine struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
-	OrderID   uuid.UUID
-	ProductID uuid.UUID
-	Price     int
-	Quantity  int
+	ID          uuid.UUID `gorm:"type:uuid;primary_key"`
+	OrderID     uuid.UUID
+	ProductID   uuid.UUID
+	ProductName string
+	Price       int
+	Quantity    int
 }
 
 type DBOrderLineContentLine struct {
 	ID          uuid.UUID `gorm:"type:uuid;primary_key"`
 	OrderLineID uuid.UUID
 	ProductID   uuid.UUID
+	ProductName string
 	Quantity    int
 }
 
+// DBOrderNumber hands out order numbers from its auto-incremented ID.
+type DBOrderNumber struct {
+	ID      int64     `gorm:"primaryKey;autoIncrement"`
+	OrderID uuid.UUID `gorm:"type:uuid;uniqueIndex"`
+}
+
+type DBOrderStatusTransition struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key"`
+	OrderID         uuid.UUID `gorm:"index"`
+	FromStatus      string
+	ToStatus        string
+	Actor           string
+	CreatedDateTime time.Time
+}
+
 type GormSLOrderRepository struct {
 	db *gorm.DB
 }
 
-func NewGormSLOrderRepository(db *gorm.DB) *GormSLOrderRepository {
-	db.AutoMigrate(&DBOrder{}, &DBOrderLine{}, &DBOrderLineContentLine{})
-	return &GormSLOrderRepository{db: db}
+func NewGormSLOrderRepository(db *gorm.DB) (*GormSLOrderRepository, error) {
+	if err := db.AutoMigrate(&DBOrder{}, &DBOrderLine{}, &DBOrderLineContentLine{}, &DBOrderStatusTransition{}, &DBOrderNumber{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate order tables: %w", err)
+	}
+	// Orders from before activity was recorded were last active when created
+	err := db.Model(&DBOrder{}).Where("last_activity_date_time IS NULL").Update("last_activity_date_time", gorm.Expr("created_date_time")).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to backfill the last activity of orders: %w", err)
+	}
+	return &GormSLOrderRepository{db: db}, nil
+}
+
+// ClearDuplicateSessionIds keeps the oldest order of every session that got
+// more than one before session IDs were unique, and clears the session ID of
+// the others, so that NewGormSLOrderRepository can create the unique index.
+// It returns the number of orders whose session ID was cleared.
+func ClearDuplicateSessionIds(ctx context.Context, db *gorm.DB) (int64, error) {
+	if !db.Migrator().HasTable(&DBOrder{}) {
+		return 0, nil
+	}
+	result := db.WithContext(ctx).Exec(`UPDATE db_orders SET session_id = '' WHERE session_id <> '' AND EXISTS (
+		SELECT 1 FROM db_orders older WHERE older.session_id = db_orders.session_id
+		AND (older.created_date_time < db_orders.created_date_time
+			OR (older.created_date_time = db_orders.created_date_time AND older.id < db_orders.id)))`)
+	return result.RowsAffected, result.Error
 }
 
 func toDBOrder(order *domain.Order) *DBOrder {
 	return &DBOrder{
-		ID:              order.ID,
-		SessionId:       order.SessionId,
-		Email:           order.Email,
-		Name:            order.Name,
-		Address:         order.Address,
-		ZipCode:         order.ZipCode,
-		City:            order.City,
-		CompanyName:     order.CompanyName,
-		Status:          order.Status,
-		CreatedDateTime: order.CreatedDateTime,
+		ID:                   order.ID,
+		SessionId:            order.SessionId,
+		CustomerID:           order.CustomerID,
+		Email:                order.Email,
+		Name:                 order.Name,
+		Address:              order.Address,
+		ZipCode:              order.ZipCode,
+		City:                 order.City,
+		CompanyName:          order.CompanyName,
+		OrderNumber:          order.OrderNumber,
+		Status:               string(order.Status),
+		CreatedDateTime:      order.CreatedDateTime,
+		LastActivityDateTime: order.LastActivityDateTime,
+		Subtotal:             order.Subtotal,
+		Discount:             order.Discount,
+		VAT:                  order.VAT,
+		Total:                order.Total,
 	}
 }
 
 func toDomainOrder(dbOrder *DBOrder) *domain.Order {
 	return &domain.Order{
-		ID:              dbOrder.ID,
-		SessionId:       dbOrder.SessionId,
-		Email:           dbOrder.Email,
-		Name:            dbOrder.Name,
-		Address:         dbOrder.Address,
-		ZipCode:         dbOrder.ZipCode,
-		City:            dbOrder.City,
-		CompanyName:     dbOrder.CompanyName,
-		Status:          dbOrder.Status,
-		CreatedDateTime: dbOrder.CreatedDateTime,
+		ID:                   dbOrder.ID,
+		SessionId:            dbOrder.SessionId,
+		CustomerID:           dbOrder.CustomerID,
+		Email:                dbOrder.Email,
+		Name:                 dbOrder.Name,
+		Address:              dbOrder.Address,
+		ZipCode:              dbOrder.ZipCode,
+		City:                 dbOrder.City,
+		CompanyName:          dbOrder.CompanyName,
+		OrderNumber:          dbOrder.OrderNumber,
+		Status:               domain.OrderStatus(dbOrder.Status),
+		CreatedDateTime:      dbOrder.CreatedDateTime,
+		LastActivityDateTime: dbOrder.LastActivityDateTime,
+		Subtotal:             dbOrder.Subtotal,
+		Discount:             dbOrder.Discount,
+		VAT:                  dbOrder.VAT,
+		Total:                dbOrder.Total,
+	}
+}
+
+func toDomainOrderStatusTransition(dbTransition *DBOrderStatusTransition) *domain.OrderStatusTransition {
+	return &domain.OrderStatusTransition{
+		ID:              dbTransition.ID,
+		OrderID:         dbTransition.OrderID,
+		FromStatus:      domain.OrderStatus(dbTransition.FromStatus),
+		ToStatus:        domain.OrderStatus(dbTransition.ToStatus),
+		Actor:           dbTransition.Actor,
+		CreatedDateTime: dbTransition.CreatedDateTime,
+	}
+}
+
+func toDBOrderLine(orderLine *domain.OrderLine) *DBOrderLine {
+	return &DBOrderLine{
+		ID:          orderLine.ID,
+		OrderID:     orderLine.OrderID,
+		ProductID:   orderLine.ProductID,
+		ProductName: orderLine.ProductName,
+		Price:       orderLine.Price,
+		Quantity:    orderLine.Quantity,
 	}
 }
 
 func toDomainOrderLine(dbOrderLine *DBOrderLine) *domain.OrderLine {
 	return &domain.OrderLine{
-		ID:        dbOrderLine.ID,
-		OrderID:   dbOrderLine.OrderID,
-		ProductID: dbOrderLine.ProductID,
-		Price:     dbOrderLine.Price,
-		Quantity:  dbOrderLine.Quantity,
+		ID:          dbOrderLine.ID,
+		OrderID:     dbOrderLine.OrderID,
+		ProductID:   dbOrderLine.ProductID,
+		ProductName: dbOrderLine.ProductName,
+		Price:       dbOrderLine.Price,
+		Quantity:    dbOrderLine.Quantity,
+	}
+}
+
+func toDBOrderLineContentLine(contentLine *domain.OrderLineContentLine) *DBOrderLineContentLine {
+	return &DBOrderLineContentLine{
+		ID:          contentLine.ID,
+		OrderLineID: contentLine.OrderLineID,
+		ProductID:   contentLine.ProductID,
+		ProductName: contentLine.ProductName,
+		Quantity:    contentLine.Quantity,
 	}
 }
 
@@ -90,100 +187,238 @@ func toDomainOrderLineContentLine(dbOrderLineContentLine *DBOrderLineContentLine
 		ID:          dbOrderLineContentLine.ID,
 		OrderLineID: dbOrderLineContentLine.OrderLineID,
 		ProductID:   dbOrderLineContentLine.ProductID,
+		ProductName: dbOrderLineContentLine.ProductName,
 		Quantity:    dbOrderLineContentLine.Quantity,
 	}
 }
 
-func (r *GormSLOrderRepository) CreateOrder(order *domain.Order) (*domain.Order, error) {
+func (r *GormSLOrderRepository) CreateOrder(ctx context.Context, order *domain.Order) (*domain.Order, error) {
 	dbOrder := toDBOrder(order)
-	if err := r.db.Create(dbOrder).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Create(dbOrder).Error; err != nil {
+		return nil, translateError(err, nil, domain.ErrSessionHasOrder)
 	}
 	return toDomainOrder(dbOrder), nil
 }
 
-func (r *GormSLOrderRepository) UpdateOrder(order *domain.Order) error {
+func (r *GormSLOrderRepository) UpdateOrder(ctx context.Context, order *domain.Order) error {
 	dbOrder := toDBOrder(order)
-	return r.db.Save(dbOrder).Error
+	return translateError(r.db.WithContext(ctx).Save(dbOrder).Error, nil, domain.ErrSessionHasOrder)
 }
 
-func (r *GormSLOrderRepository) GetOrderById(id uuid.UUID) (*domain.Order, error) {
+// UpdateOrderLastActivity records when the order was last changed without
+// touching its other columns.
+func (r *GormSLOrderRepository) UpdateOrderLastActivity(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&DBOrder{}).Where("id = ?", id).Update("last_activity_date_time", at).Error
+}
+
+func (r *GormSLOrderRepository) GetOrderById(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
 	var dbOrder DBOrder
-	if err := r.db.Where("id = ?", id).First(&dbOrder).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&dbOrder).Error; err != nil {
+		return nil, translateError(err, domain.ErrOrderNotFound, nil)
 	}
 	return toDomainOrder(&dbOrder), nil
 }
 
-func (r *GormSLOrderRepository) DeleteOrder(id uuid.UUID) error {
-	return r.db.Delete(&DBOrder{}, id).Error
+// DeleteOrder deletes the order together with its lines, content lines,
+// status transitions, order number, payments and stock reservations in a
+// single transaction.
+func (r *GormSLOrderRepository) DeleteOrder(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteOrderRows(tx, []uuid.UUID{id}); err != nil {
+			return err
+		}
+		return tx.Delete(&DBOrder{}, id).Error
+	})
+}
+
+// deleteOrderRows deletes the rows that belong to the given orders.
+func deleteOrderRows(tx *gorm.DB, orderIDs []uuid.UUID) error {
+	orderLineIDs := tx.Model(&DBOrderLine{}).Select("id").Where("order_id IN ?", orderIDs)
+	if err := tx.Where("order_line_id IN (?)", orderLineIDs).Delete(&DBOrderLineContentLine{}).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&DBOrderLine{}, &DBOrderStatusTransition{}, &DBOrderNumber{}, &DBPayment{}, &DBStockReservation{}} {
+		if err := tx.Where("order_id IN ?", orderIDs).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *GormSLOrderRepository) CreateOrderLine(orderLine *domain.OrderLine) (*domain.OrderLine, error) {
-	dbOrderLine := &DBOrderLine{
-		ID:        orderLine.ID,
-		OrderID:   orderLine.OrderID,
-		ProductID: orderLine.ProductID,
-		Price:     orderLine.Price,
-		Quantity:  orderLine.Quantity,
+// DeleteCreatedOrders deletes the given orders that are still carts, so an
+// order checked out after it was picked for deletion is left alone.
+func (r *GormSLOrderRepository) DeleteCreatedOrders(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	if len(ids) == 0 {
+		return nil, nil
 	}
-	if err := r.db.Create(dbOrderLine).Error; err != nil {
+
+	var createdIDs []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&DBOrder{}).Where("id IN ? AND status = ?", ids, domain.OrderStatusCreated).Pluck("id", &createdIDs).Error
+		if err != nil || len(createdIDs) == 0 {
+			return err
+		}
+
+		if err := deleteOrderRows(tx, createdIDs); err != nil {
+			return err
+		}
+
+		return tx.Where("id IN ?", createdIDs).Delete(&DBOrder{}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
-	return toDomainOrderLine(dbOrderLine), nil
+	return createdIDs, nil
 }
 
-func (r *GormSLOrderRepository) UpdateOrderLine(orderLine *domain.OrderLine) error {
-	dbOrderLine := &DBOrderLine{
-		ID:        orderLine.ID,
-		OrderID:   orderLine.OrderID,
-		ProductID: orderLine.ProductID,
-		Price:     orderLine.Price,
-		Quantity:  orderLine.Quantity,
+// DeleteCreatedOrdersBefore deletes up to limit of the carts that have been
+// inactive the longest and were last active before the given time, except the
+// excluded ones. Carts are picked and deleted in the same transaction.
+func (r *GormSLOrderRepository) DeleteCreatedOrdersBefore(ctx context.Context, before time.Time, limit int, exclude []uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := createdOrdersBefore(tx, before, limit, exclude).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		if err := deleteOrderRows(tx, ids); err != nil {
+			return err
+		}
+
+		return tx.Where("id IN ?", ids).Delete(&DBOrder{}).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-	return r.db.Save(dbOrderLine).Error
+	return ids, nil
 }
 
-func (r *GormSLOrderRepository) GetOrderLineById(id uuid.UUID) (*domain.OrderLine, error) {
-	var dbOrderLine DBOrderLine
-	if err := r.db.Where("id = ?", id).First(&dbOrderLine).Error; err != nil {
+func (r *GormSLOrderRepository) GetCreatedOrderIdsBefore(ctx context.Context, before time.Time, limit int, exclude []uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := createdOrdersBefore(r.db.WithContext(ctx), before, limit, exclude).Pluck("id", &ids).Error; err != nil {
 		return nil, err
 	}
-	return toDomainOrderLine(&dbOrderLine), nil
+	return ids, nil
 }
 
-func (r *GormSLOrderRepository) DeleteOrderLine(id uuid.UUID) error {
-	return r.db.Delete(&DBOrderLine{}, id).Error
+// createdOrdersBefore selects the carts last active before the given time,
+// longest inactive first. The ID breaks ties so that the same carts are
+// picked every time.
+func createdOrdersBefore(db *gorm.DB, before time.Time, limit int, exclude []uuid.UUID) *gorm.DB {
+	db = db.Model(&DBOrder{}).Where("status = ? AND last_activity_date_time < ?", domain.OrderStatusCreated, before)
+	if len(exclude) > 0 {
+		db = db.Where("id NOT IN ?", exclude)
+	}
+	return db.Order("last_activity_date_time, id").Limit(limit)
 }
 
-func (r *GormSLOrderRepository) CreateOrderLineContentLine(contentLine *domain.OrderLineContentLine) (*domain.OrderLineContentLine, error) {
-	dbOrderLineContentLine := &DBOrderLineContentLine{
-		ID:          contentLine.ID,
-		OrderLineID: contentLine.OrderLineID,
-		ProductID:   contentLine.ProductID,
-		Quantity:    contentLine.Quantity,
+// DeleteOrphanedOrderRows removes lines, content lines, status transitions,
+// order numbers, payments and stock reservations whose order or order line
+// no longer exists.
+func (r *GormSLOrderRepository) DeleteOrphanedOrderRows(ctx context.Context) (*domain.OrphanedOrderRows, error) {
+	result := &domain.OrphanedOrderRows{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orderIDs := tx.Model(&DBOrder{}).Select("id")
+
+		deleted := tx.Where("order_id NOT IN (?)", orderIDs).Delete(&DBOrderLine{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.OrderLines = deleted.RowsAffected
+
+		orderLineIDs := tx.Model(&DBOrderLine{}).Select("id")
+		deleted = tx.Where("order_line_id NOT IN (?)", orderLineIDs).Delete(&DBOrderLineContentLine{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.ContentLines = deleted.RowsAffected
+
+		deleted = tx.Where("order_id NOT IN (?)", orderIDs).Delete(&DBOrderStatusTransition{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.StatusTransitions = deleted.RowsAffected
+
+		deleted = tx.Where("order_id NOT IN (?)", orderIDs).Delete(&DBOrderNumber{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.OrderNumbers = deleted.RowsAffected
+
+		deleted = tx.Where("order_id NOT IN (?)", orderIDs).Delete(&DBPayment{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.Payments = deleted.RowsAffected
+
+		deleted = tx.Where("order_id NOT IN (?)", orderIDs).Delete(&DBStockReservation{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.StockReservations = deleted.RowsAffected
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err := r.db.Create(dbOrderLineContentLine).Error; err != nil {
+	return result, nil
+}
+
+func (r *GormSLOrderRepository) CreateOrderLine(ctx context.Context, orderLine *domain.OrderLine) (*domain.OrderLine, error) {
+	dbOrderLine := toDBOrderLine(orderLine)
+	if err := r.db.WithContext(ctx).Create(dbOrderLine).Error; err != nil {
+		return nil, err
+	}
+	return toDomainOrderLine(dbOrderLine), nil
+}
+
+func (r *GormSLOrderRepository) UpdateOrderLine(ctx context.Context, orderLine *domain.OrderLine) error {
+	dbOrderLine := toDBOrderLine(orderLine)
+	return r.db.WithContext(ctx).Save(dbOrderLine).Error
+}
+
+func (r *GormSLOrderRepository) GetOrderLineById(ctx context.Context, id uuid.UUID) (*domain.OrderLine, error) {
+	var dbOrderLine DBOrderLine
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&dbOrderLine).Error; err != nil {
+		return nil, translateError(err, domain.ErrOrderLineNotFound, nil)
+	}
+	return toDomainOrderLine(&dbOrderLine), nil
+}
+
+func (r *GormSLOrderRepository) DeleteOrderLine(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&DBOrderLine{}, id).Error
+}
+
+func (r *GormSLOrderRepository) CreateOrderLineContentLine(ctx context.Context, contentLine *domain.OrderLineContentLine) (*domain.OrderLineContentLine, error) {
+	dbOrderLineContentLine := toDBOrderLineContentLine(contentLine)
+	if err := r.db.WithContext(ctx).Create(dbOrderLineContentLine).Error; err != nil {
 		return nil, err
 	}
 	return toDomainOrderLineContentLine(dbOrderLineContentLine), nil
 }
 
-func (r *GormSLOrderRepository) DeleteOrderLineContentLine(id uuid.UUID) error {
-	return r.db.Delete(&DBOrderLineContentLine{}, id).Error
+func (r *GormSLOrderRepository) UpdateOrderLineContentLine(ctx context.Context, contentLine *domain.OrderLineContentLine) error {
+	dbOrderLineContentLine := toDBOrderLineContentLine(contentLine)
+	return r.db.WithContext(ctx).Save(dbOrderLineContentLine).Error
+}
+
+func (r *GormSLOrderRepository) DeleteOrderLineContentLine(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&DBOrderLineContentLine{}, id).Error
 }
 
-func (r *GormSLOrderRepository) GetOrderBySessionId(sessionId string) (*domain.Order, error) {
+func (r *GormSLOrderRepository) GetOrderBySessionId(ctx context.Context, sessionId string) (*domain.Order, error) {
 	var dbOrder DBOrder
-	if err := r.db.Where("session_id = ?", sessionId).First(&dbOrder).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Where("session_id = ?", sessionId).First(&dbOrder).Error; err != nil {
+		return nil, translateError(err, domain.ErrOrderNotFound, nil)
 	}
 	return toDomainOrder(&dbOrder), nil
 }
 
-func (r *GormSLOrderRepository) GetOrderLinesByOrderId(orderId uuid.UUID) ([]*domain.OrderLine, error) {
+func (r *GormSLOrderRepository) GetOrderLinesByOrderId(ctx context.Context, orderId uuid.UUID) ([]*domain.OrderLine, error) {
 	var dbOrderLines []DBOrderLine
-	if err := r.db.Where("order_id = ?", orderId).Find(&dbOrderLines).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderId).Find(&dbOrderLines).Error; err != nil {
 		return nil, err
 	}
 	orderLines := make([]*domain.OrderLine, len(dbOrderLines))
@@ -193,9 +428,9 @@ func (r *GormSLOrderRepository) GetOrderLinesByOrderId(orderId uuid.UUID) ([]*do
 	return orderLines, nil
 }
 
-func (r *GormSLOrderRepository) GetOrderLineContentLinesByOrderLineId(orderLineId uuid.UUID) ([]*domain.OrderLineContentLine, error) {
+func (r *GormSLOrderRepository) GetOrderLineContentLinesByOrderLineId(ctx context.Context, orderLineId uuid.UUID) ([]*domain.OrderLineContentLine, error) {
 	var dbOrderLineContentLines []DBOrderLineContentLine
-	if err := r.db.Where("order_line_id = ?", orderLineId).Find(&dbOrderLineContentLines).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("order_line_id = ?", orderLineId).Find(&dbOrderLineContentLines).Error; err != nil {
 		return nil, err
 	}
 	contentLines := make([]*domain.OrderLineContentLine, len(dbOrderLineContentLines))
@@ -205,14 +440,113 @@ func (r *GormSLOrderRepository) GetOrderLineContentLinesByOrderLineId(orderLineI
 	return contentLines, nil
 }
 
-func (r *GormSLOrderRepository) GetOrderByStatus(status string) ([]*domain.Order, error) {
-	var dbOrders []DBOrder
-	if err := r.db.Where("status = ?", status).Find(&dbOrders).Error; err != nil {
+func (r *GormSLOrderRepository) GetOrderLineContentLinesByOrderLineIds(ctx context.Context, orderLineIds []uuid.UUID) ([]*domain.OrderLineContentLine, error) {
+	if len(orderLineIds) == 0 {
+		return []*domain.OrderLineContentLine{}, nil
+	}
+
+	var dbOrderLineContentLines []DBOrderLineContentLine
+	if err := r.db.WithContext(ctx).Where("order_line_id IN ?", orderLineIds).Find(&dbOrderLineContentLines).Error; err != nil {
 		return nil, err
 	}
+	contentLines := make([]*domain.OrderLineContentLine, len(dbOrderLineContentLines))
+	for i, dbOrderLineContentLine := range dbOrderLineContentLines {
+		contentLines[i] = toDomainOrderLineContentLine(&dbOrderLineContentLine)
+	}
+	return contentLines, nil
+}
+
+var orderSortColumns = map[string]string{
+	"created_date_time": "created_date_time",
+	"order_number":      "order_number",
+	"name":              "name",
+	"email":             "email",
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, using \ as escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func containsPattern(value string) string {
+	return "%" + likeEscaper.Replace(value) + "%"
+}
+
+func (r *GormSLOrderRepository) ListOrders(ctx context.Context, query domain.OrderQuery) ([]*domain.Order, int64, error) {
+	db := r.db.WithContext(ctx).Model(&DBOrder{})
+	if query.CustomerID != nil {
+		db = db.Where("customer_id = ?", *query.CustomerID)
+	}
+	if len(query.Statuses) > 0 {
+		db = db.Where("status IN ?", query.Statuses)
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("created_date_time >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("created_date_time < ?", *query.CreatedBefore)
+	}
+	if query.Email != "" {
+		db = db.Where("LOWER(email) = LOWER(?)", query.Email)
+	}
+	if query.ZipCode != "" {
+		db = db.Where("zip_code = ?", query.ZipCode)
+	}
+	if query.CompanyName != "" {
+		db = db.Where(`company_name LIKE ? ESCAPE '\'`, containsPattern(query.CompanyName))
+	}
+	if query.Search != "" {
+		pattern := containsPattern(query.Search)
+		db = db.Where(
+			`(email LIKE @pattern ESCAPE '\' OR name LIKE @pattern ESCAPE '\' OR address LIKE @pattern ESCAPE '\' OR `+
+				`city LIKE @pattern ESCAPE '\' OR company_name LIKE @pattern ESCAPE '\' OR order_number LIKE @pattern ESCAPE '\')`,
+			sql.Named("pattern", pattern))
+	}
+
+	var total int64
+	err := db.Session(&gorm.Session{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dbOrders []DBOrder
+	err = db.Order(orderBy(query.Sort, orderSortColumns)).Offset(query.Page.Offset).Limit(query.Page.Limit).Find(&dbOrders).Error
+	if err != nil {
+		return nil, 0, err
+	}
 	orders := make([]*domain.Order, len(dbOrders))
 	for i, dbOrder := range dbOrders {
 		orders[i] = toDomainOrder(&dbOrder)
 	}
-	return orders, nil
+	return orders, total, nil
+}
+
+func (r *GormSLOrderRepository) CreateOrderStatusTransition(ctx context.Context, transition *domain.OrderStatusTransition) error {
+	dbTransition := &DBOrderStatusTransition{
+		ID:              transition.ID,
+		OrderID:         transition.OrderID,
+		FromStatus:      string(transition.FromStatus),
+		ToStatus:        string(transition.ToStatus),
+		Actor:           transition.Actor,
+		CreatedDateTime: transition.CreatedDateTime,
+	}
+	return r.db.WithContext(ctx).Create(dbTransition).Error
+}
+
+func (r *GormSLOrderRepository) GetOrderStatusTransitionsByOrderId(ctx context.Context, orderId uuid.UUID) ([]*domain.OrderStatusTransition, error) {
+	var dbTransitions []DBOrderStatusTransition
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderId).Order("created_date_time asc").Find(&dbTransitions).Error; err != nil {
+		return nil, err
+	}
+	transitions := make([]*domain.OrderStatusTransition, len(dbTransitions))
+	for i, dbTransition := range dbTransitions {
+		transitions[i] = toDomainOrderStatusTransition(&dbTransition)
+	}
+	return transitions, nil
+}
+
+func (r *GormSLOrderRepository) NextOrderNumber(ctx context.Context, orderId uuid.UUID) (int64, error) {
+	dbOrderNumber := &DBOrderNumber{OrderID: orderId}
+	if err := r.db.WithContext(ctx).Create(dbOrderNumber).Error; err != nil {
+		return 0, err
+	}
+	return dbOrderNumber.ID, nil
 }
@@ -0,0 +1,183 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "commerce.db")+"?_txlock=immediate"), &gorm.Config{
+		TranslateError: true,
+		Logger:         gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDeleteOrphanedOrderRows(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	repository, err := NewGormSLOrderRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGormSLPaymentRepository(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGormSLInventoryRepository(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// One order that still exists and one that was deleted without its rows
+	for _, orderID := range []uuid.UUID{uuid.New(), uuid.New()} {
+		orderLineID := uuid.New()
+		rows := []interface{}{
+			&DBOrder{ID: orderID, Status: "paid"},
+			&DBOrderLine{ID: orderLineID, OrderID: orderID},
+			&DBOrderLineContentLine{ID: uuid.New(), OrderLineID: orderLineID},
+			&DBOrderStatusTransition{ID: uuid.New(), OrderID: orderID},
+			&DBOrderNumber{OrderID: orderID},
+			&DBPayment{ID: uuid.New(), OrderID: orderID, Provider: "fake", ProviderReference: orderID.String()},
+			&DBStockReservation{ID: uuid.New(), OrderID: orderID, OrderLineID: orderLineID},
+		}
+		for _, row := range rows {
+			if err := db.Create(row).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var deletedOrder DBOrder
+	if err := db.Last(&deletedOrder).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&deletedOrder).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := repository.DeleteOrphanedOrderRows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int64{
+		"order lines":        deleted.OrderLines,
+		"content lines":      deleted.ContentLines,
+		"status transitions": deleted.StatusTransitions,
+		"order numbers":      deleted.OrderNumbers,
+		"payments":           deleted.Payments,
+		"stock reservations": deleted.StockReservations,
+	}
+	for name, count := range counts {
+		if count != 1 {
+			t.Errorf("deleted %d %s, want 1", count, name)
+		}
+	}
+
+	remaining := map[string]interface{}{
+		"order lines":        &DBOrderLine{},
+		"content lines":      &DBOrderLineContentLine{},
+		"status transitions": &DBOrderStatusTransition{},
+		"order numbers":      &DBOrderNumber{},
+		"payments":           &DBPayment{},
+		"stock reservations": &DBStockReservation{},
+	}
+	for name, model := range remaining {
+		var count int64
+		if err := db.Model(model).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%d %s left, want the 1 of the remaining order", count, name)
+		}
+	}
+}
+
+func TestCreateOrderSessionIsUnique(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionId string
+		wantErr   error
+	}{
+		{name: "session order", sessionId: uuid.NewString(), wantErr: domain.ErrSessionHasOrder},
+		{name: "staff order", sessionId: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repository, err := NewGormSLOrderRepository(newTestDB(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := repository.CreateOrder(ctx, &domain.Order{ID: uuid.New(), SessionId: tt.sessionId}); err != nil {
+				t.Fatal(err)
+			}
+			_, err = repository.CreateOrder(ctx, &domain.Order{ID: uuid.New(), SessionId: tt.sessionId})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second order: error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClearDuplicateSessionIdsKeepsOldestSessionOrder(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	// A database from before session IDs were unique
+	if err := db.Exec("CREATE TABLE db_orders (id uuid PRIMARY KEY, session_id text, created_date_time datetime)").Error; err != nil {
+		t.Fatal(err)
+	}
+	sessionId := uuid.NewString()
+	oldest, newest := uuid.New(), uuid.New()
+	for i, id := range []uuid.UUID{oldest, newest} {
+		err := db.Exec("INSERT INTO db_orders (id, session_id, created_date_time) VALUES (?, ?, ?)", id, sessionId, time.Date(2026, 3, 2, 10, i, 0, 0, time.UTC)).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := NewGormSLOrderRepository(db); err == nil {
+		t.Fatal("migrated a database with duplicate session IDs")
+	}
+
+	cleared, err := ClearDuplicateSessionIds(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared != 1 {
+		t.Errorf("cleared %d session IDs, want 1", cleared)
+	}
+
+	repository, err := NewGormSLOrderRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err := repository.GetOrderBySessionId(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != oldest {
+		t.Errorf("session order = %s, want the oldest order %s", order.ID, oldest)
+	}
+	if !order.LastActivityDateTime.Equal(order.CreatedDateTime) {
+		t.Errorf("last activity = %s, want the creation time %s", order.LastActivityDateTime, order.CreatedDateTime)
+	}
+	if _, err := repository.CreateOrder(ctx, &domain.Order{ID: uuid.New(), SessionId: sessionId}); !errors.Is(err, domain.ErrSessionHasOrder) {
+		t.Errorf("error = %v, want %v", err, domain.ErrSessionHasOrder)
+	}
+}
@@ -0,0 +1,95 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/gorm"
+)
+
+type DBPayment struct {
+	ID                uuid.UUID `gorm:"type:uuid;primary_key"`
+	OrderID           uuid.UUID `gorm:"index"`
+	Provider          string    `gorm:"uniqueIndex:idx_payment_provider_reference"`
+	ProviderReference string    `gorm:"uniqueIndex:idx_payment_provider_reference"`
+	Amount            int
+	Currency          string
+	Status            string
+	RedirectURL       string
+	CreatedDateTime   time.Time
+	UpdatedDateTime   time.Time
+}
+
+type GormSLPaymentRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSLPaymentRepository(db *gorm.DB) (*GormSLPaymentRepository, error) {
+	if err := db.AutoMigrate(&DBPayment{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate payment tables: %w", err)
+	}
+	return &GormSLPaymentRepository{db: db}, nil
+}
+
+func toDBPayment(payment *domain.Payment) *DBPayment {
+	return &DBPayment{
+		ID:                payment.ID,
+		OrderID:           payment.OrderID,
+		Provider:          payment.Provider,
+		ProviderReference: payment.ProviderReference,
+		Amount:            payment.Amount,
+		Currency:          payment.Currency,
+		Status:            string(payment.Status),
+		RedirectURL:       payment.RedirectURL,
+		CreatedDateTime:   payment.CreatedDateTime,
+		UpdatedDateTime:   payment.UpdatedDateTime,
+	}
+}
+
+func toDomainPayment(dbPayment *DBPayment) *domain.Payment {
+	return &domain.Payment{
+		ID:                dbPayment.ID,
+		OrderID:           dbPayment.OrderID,
+		Provider:          dbPayment.Provider,
+		ProviderReference: dbPayment.ProviderReference,
+		Amount:            dbPayment.Amount,
+		Currency:          dbPayment.Currency,
+		Status:            domain.PaymentStatus(dbPayment.Status),
+		RedirectURL:       dbPayment.RedirectURL,
+		CreatedDateTime:   dbPayment.CreatedDateTime,
+		UpdatedDateTime:   dbPayment.UpdatedDateTime,
+	}
+}
+
+func (r *GormSLPaymentRepository) CreatePayment(ctx context.Context, payment *domain.Payment) error {
+	dbPayment := toDBPayment(payment)
+	return r.db.WithContext(ctx).Create(dbPayment).Error
+}
+
+func (r *GormSLPaymentRepository) UpdatePayment(ctx context.Context, payment *domain.Payment) error {
+	dbPayment := toDBPayment(payment)
+	return r.db.WithContext(ctx).Save(dbPayment).Error
+}
+
+func (r *GormSLPaymentRepository) GetPaymentByProviderReference(ctx context.Context, provider string, reference string) (*domain.Payment, error) {
+	var dbPayment DBPayment
+	if err := r.db.WithContext(ctx).Where("provider = ? AND provider_reference = ?", provider, reference).First(&dbPayment).Error; err != nil {
+		return nil, translateError(err, domain.ErrPaymentNotFound, nil)
+	}
+	return toDomainPayment(&dbPayment), nil
+}
+
+func (r *GormSLPaymentRepository) GetPaymentsByOrderId(ctx context.Context, orderId uuid.UUID) ([]*domain.Payment, error) {
+	var dbPayments []DBPayment
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderId).Order("created_date_time asc").Find(&dbPayments).Error; err != nil {
+		return nil, err
+	}
+	payments := make([]*domain.Payment, len(dbPayments))
+	for i, dbPayment := range dbPayments {
+		payments[i] = toDomainPayment(&dbPayment)
+	}
+	return payments, nil
+}
@@ -1,6 +1,9 @@
 package adapters
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/google/uuid"
 	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
 	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
@@ -31,9 +34,11 @@ type GormSLProductRepository struct {
 	logger ports.Logger
 }
 
-func NewGormSLProductRepository(db *gorm.DB, logger ports.Logger) *GormSLProductRepository {
-	db.AutoMigrate(&DBProduct{}, &DBProductGroup{})
-	return &GormSLProductRepository{db: db}
+func NewGormSLProductRepository(db *gorm.DB, logger ports.Logger) (*GormSLProductRepository, error) {
+	if err := db.AutoMigrate(&DBProduct{}, &DBProductGroup{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate product tables: %w", err)
+	}
+	return &GormSLProductRepository{db: db}, nil
 }
 
 func toDBProduct(product *domain.Product) *DBProduct {
@@ -82,32 +87,36 @@ func toDomainProductGroup(dbProductGroup *DBProductGroup) *domain.ProductGroup {
 	}
 }
 
-func (r *GormSLProductRepository) CreateProduct(product *domain.Product) error {
+func (r *GormSLProductRepository) CreateProduct(ctx context.Context, product *domain.Product) error {
 	dbProduct := toDBProduct(product)
-	return r.db.Create(dbProduct).Error
+	return r.db.WithContext(ctx).Create(dbProduct).Error
 }
 
-func (r *GormSLProductRepository) UpdateProduct(product *domain.Product) error {
+func (r *GormSLProductRepository) UpdateProduct(ctx context.Context, product *domain.Product) error {
 	dbProduct := toDBProduct(product)
-	return r.db.Save(dbProduct).Error
+	return r.db.WithContext(ctx).Save(dbProduct).Error
 }
 
-func (r *GormSLProductRepository) DeleteProduct(productID uuid.UUID) error {
-	return r.db.Delete(&DBProduct{}, productID).Error
+func (r *GormSLProductRepository) DeleteProduct(ctx context.Context, productID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&DBProduct{}, productID).Error
 }
 
-func (r *GormSLProductRepository) GetProduct(productID uuid.UUID) (*domain.Product, error) {
+func (r *GormSLProductRepository) GetProduct(ctx context.Context, productID uuid.UUID) (*domain.Product, error) {
 	var dbProduct DBProduct
-	err := r.db.Where("id = ?", productID).First(&dbProduct).Error
+	err := r.db.WithContext(ctx).Where("id = ?", productID).First(&dbProduct).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, domain.ErrProductNotFound, nil)
 	}
 	return toDomainProduct(&dbProduct), nil
 }
 
-func (r *GormSLProductRepository) ListProducts() ([]domain.Product, error) {
+func (r *GormSLProductRepository) GetProductsByIDs(ctx context.Context, productIDs []uuid.UUID) ([]domain.Product, error) {
+	if len(productIDs) == 0 {
+		return []domain.Product{}, nil
+	}
+
 	var dbProducts []DBProduct
-	err := r.db.Find(&dbProducts).Error
+	err := r.db.WithContext(ctx).Where("id IN ?", productIDs).Find(&dbProducts).Error
 	if err != nil {
 		return nil, err
 	}
@@ -118,32 +127,97 @@ func (r *GormSLProductRepository) ListProducts() ([]domain.Product, error) {
 	return products, nil
 }
 
-func (r *GormSLProductRepository) CreateProductGroup(productGroup *domain.ProductGroup) error {
+var productSortColumns = map[string]string{
+	"order": `"order"`,
+	"name":  "name",
+	"price": "price",
+}
+
+var productGroupSortColumns = map[string]string{
+	"order": `"order"`,
+	"name":  "name",
+}
+
+// orderBy builds an ORDER BY for a sort. The ID breaks ties so that pages
+// don't overlap.
+func orderBy(sort domain.Sort, columns map[string]string) string {
+	direction := "asc"
+	if sort.Descending {
+		direction = "desc"
+	}
+	return columns[sort.Field] + " " + direction + ", id asc"
+}
+
+func (r *GormSLProductRepository) ListProducts(ctx context.Context, query domain.ProductQuery) ([]domain.Product, int64, error) {
+	session := r.db.WithContext(ctx)
+	db := session.Model(&DBProduct{})
+	if query.ProductGroupID != nil {
+		db = db.Where("product_group_id = ?", *query.ProductGroupID)
+	}
+	if query.IsSold != nil {
+		db = db.Where("product_group_id IN (?)", session.Model(&DBProductGroup{}).Select("id").Where("is_sold = ?", *query.IsSold))
+	}
+	if query.IsConfigurable != nil {
+		db = db.Where("is_configurable = ?", *query.IsConfigurable)
+	}
+	if query.IsSoldSeparately != nil {
+		db = db.Where("is_sold_separately = ?", *query.IsSoldSeparately)
+	}
+	if query.MinPrice != nil {
+		db = db.Where("price >= ?", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		db = db.Where("price <= ?", *query.MaxPrice)
+	}
+
+	var total int64
+	err := db.Session(&gorm.Session{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dbProducts []DBProduct
+	err = db.Order(orderBy(query.Sort, productSortColumns)).Offset(query.Page.Offset).Limit(query.Page.Limit).Find(&dbProducts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	products := make([]domain.Product, len(dbProducts))
+	for i, dbProduct := range dbProducts {
+		products[i] = *toDomainProduct(&dbProduct)
+	}
+	return products, total, nil
+}
+
+func (r *GormSLProductRepository) CreateProductGroup(ctx context.Context, productGroup *domain.ProductGroup) error {
 	dbProductGroup := toDBProductGroup(productGroup)
-	return r.db.Create(dbProductGroup).Error
+	return r.db.WithContext(ctx).Create(dbProductGroup).Error
 }
 
-func (r *GormSLProductRepository) UpdateProductGroup(productGroup *domain.ProductGroup) error {
+func (r *GormSLProductRepository) UpdateProductGroup(ctx context.Context, productGroup *domain.ProductGroup) error {
 	dbProductGroup := toDBProductGroup(productGroup)
-	return r.db.Save(dbProductGroup).Error
+	return r.db.WithContext(ctx).Save(dbProductGroup).Error
 }
 
-func (r *GormSLProductRepository) DeleteProductGroup(productGroupID uuid.UUID) error {
-	return r.db.Delete(&DBProductGroup{}, productGroupID).Error
+func (r *GormSLProductRepository) DeleteProductGroup(ctx context.Context, productGroupID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&DBProductGroup{}, productGroupID).Error
 }
 
-func (r *GormSLProductRepository) GetProductGroup(productGroupID uuid.UUID) (*domain.ProductGroup, error) {
+func (r *GormSLProductRepository) GetProductGroup(ctx context.Context, productGroupID uuid.UUID) (*domain.ProductGroup, error) {
 	var dbProductGroup DBProductGroup
-	err := r.db.Where("id = ?", productGroupID).First(&dbProductGroup).Error
+	err := r.db.WithContext(ctx).Where("id = ?", productGroupID).First(&dbProductGroup).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, domain.ErrProductGroupNotFound, nil)
 	}
 	return toDomainProductGroup(&dbProductGroup), nil
 }
 
-func (r *GormSLProductRepository) ListProductGroups() ([]domain.ProductGroup, error) {
+func (r *GormSLProductRepository) GetProductGroupsByIDs(ctx context.Context, productGroupIDs []uuid.UUID) ([]domain.ProductGroup, error) {
+	if len(productGroupIDs) == 0 {
+		return []domain.ProductGroup{}, nil
+	}
+
 	var dbProductGroups []DBProductGroup
-	err := r.db.Find(&dbProductGroups).Error
+	err := r.db.WithContext(ctx).Where("id IN ?", productGroupIDs).Find(&dbProductGroups).Error
 	if err != nil {
 		return nil, err
 	}
@@ -154,9 +228,63 @@ func (r *GormSLProductRepository) ListProductGroups() ([]domain.ProductGroup, er
 	return productGroups, nil
 }
 
-func (r *GormSLProductRepository) ListProductsByProductGroupID(productGroupID uuid.UUID) ([]domain.Product, error) {
+func (r *GormSLProductRepository) ListProductGroups(ctx context.Context, query domain.ProductGroupQuery) ([]domain.ProductGroup, int64, error) {
+	db := r.db.WithContext(ctx).Model(&DBProductGroup{})
+	if query.IsSold != nil {
+		db = db.Where("is_sold = ?", *query.IsSold)
+	}
+
+	var total int64
+	err := db.Session(&gorm.Session{}).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var dbProductGroups []DBProductGroup
+	err = db.Order(orderBy(query.Sort, productGroupSortColumns)).Offset(query.Page.Offset).Limit(query.Page.Limit).Find(&dbProductGroups).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	productGroups := make([]domain.ProductGroup, len(dbProductGroups))
+	for i, dbProductGroup := range dbProductGroups {
+		productGroups[i] = *toDomainProductGroup(&dbProductGroup)
+	}
+	return productGroups, total, nil
+}
+
+func (r *GormSLProductRepository) ListProductsByProductGroupID(ctx context.Context, productGroupID uuid.UUID) ([]domain.Product, error) {
+	var dbProducts []DBProduct
+	err := r.db.WithContext(ctx).Where("product_group_id = ?", productGroupID).Find(&dbProducts).Error
+	if err != nil {
+		return nil, err
+	}
+	products := make([]domain.Product, len(dbProducts))
+	for i, dbProduct := range dbProducts {
+		products[i] = *toDomainProduct(&dbProduct)
+	}
+	return products, nil
+}
+
+func (r *GormSLProductRepository) ListProductsByProductGroupIDs(ctx context.Context, productGroupIDs []uuid.UUID) ([]domain.Product, error) {
+	if len(productGroupIDs) == 0 {
+		return []domain.Product{}, nil
+	}
+
+	var dbProducts []DBProduct
+	err := r.db.WithContext(ctx).Where("product_group_id IN ?", productGroupIDs).Find(&dbProducts).Error
+	if err != nil {
+		return nil, err
+	}
+	products := make([]domain.Product, len(dbProducts))
+	for i, dbProduct := range dbProducts {
+		products[i] = *toDomainProduct(&dbProduct)
+	}
+	return products, nil
+}
+
+func (r *GormSLProductRepository) ListProductsByConfiguringProductGroupID(ctx context.Context, productGroupID uuid.UUID) ([]domain.Product, error) {
 	var dbProducts []DBProduct
-	err := r.db.Where("product_group_id = ?", productGroupID).Find(&dbProducts).Error
+	err := r.db.WithContext(ctx).Where("configured_by_product_group_id = ?", productGroupID).Find(&dbProducts).Error
 	if err != nil {
 		return nil, err
 	}
@@ -167,9 +295,9 @@ func (r *GormSLProductRepository) ListProductsByProductGroupID(productGroupID uu
 	return products, nil
 }
 
-func (r *GormSLProductRepository) ListProductGroupsWithProducts() ([]domain.ProductGroupWithProducts, error) {
+func (r *GormSLProductRepository) ListProductGroupsWithProducts(ctx context.Context) ([]domain.ProductGroupWithProducts, error) {
 	var dbProductGroups []DBProductGroup
-	err := r.db.Where("is_sold = ?", true).Order("\"order\" asc").Find(&dbProductGroups).Error
+	err := r.db.WithContext(ctx).Where("is_sold = ?", true).Order("\"order\" asc").Find(&dbProductGroups).Error
 	if err != nil {
 		return nil, err
 	}
@@ -177,7 +305,7 @@ func (r *GormSLProductRepository) ListProductGroupsWithProducts() ([]domain.Prod
 	var productGroupsWithProducts []domain.ProductGroupWithProducts
 	for _, dbProductGroup := range dbProductGroups {
 		var dbProducts []DBProduct
-		err := r.db.Where("product_group_id = ? AND is_sold_separately = ?", dbProductGroup.ID, true).Find(&dbProducts).Error
+		err := r.db.WithContext(ctx).Where("product_group_id = ? AND is_sold_separately = ?", dbProductGroup.ID, true).Find(&dbProducts).Error
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,53 @@
+package adapters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+func TestListProductsBySoldProductGroup(t *testing.T) {
+	ctx := context.Background()
+	repository, err := NewGormSLProductRepository(newTestDB(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sold, notSold := uuid.New(), uuid.New()
+	for _, productGroup := range []*domain.ProductGroup{{ID: sold, Name: "Bars", IsSold: true}, {ID: notSold, Name: "Pralines"}} {
+		if err := repository.CreateProductGroup(ctx, productGroup); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bar := &domain.Product{ID: uuid.New(), Name: "Dark bar", ProductGroupID: sold}
+	praline := &domain.Product{ID: uuid.New(), Name: "Hazelnut praline", ProductGroupID: notSold}
+	for _, product := range []*domain.Product{bar, praline} {
+		if err := repository.CreateProduct(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		isSold bool
+		want   uuid.UUID
+	}{
+		{isSold: true, want: bar.ID},
+		{isSold: false, want: praline.ID},
+	}
+
+	for _, tt := range tests {
+		query, err := domain.ProductQuery{IsSold: &tt.isSold}.Normalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		products, total, err := repository.ListProducts(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(products) != 1 || products[0].ID != tt.want {
+			t.Errorf("is sold %t: got %d of %d products, want only %s", tt.isSold, len(products), total, tt.want)
+		}
+	}
+}
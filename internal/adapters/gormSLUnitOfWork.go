@@ -0,0 +1,45 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+	"gorm.io/gorm"
+)
+
+// GormSLUnitOfWork runs repository calls in a single SQLite transaction. The
+// repositories handed out inside a transaction skip the migrations done by
+// their constructors, so the tables must already have been migrated.
+type GormSLUnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewGormSLUnitOfWork(db *gorm.DB) *GormSLUnitOfWork {
+	return &GormSLUnitOfWork{db: db}
+}
+
+func (u *GormSLUnitOfWork) Do(ctx context.Context, fn func(repositories ports.Repositories) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormSLRepositories{tx: tx})
+	})
+}
+
+type gormSLRepositories struct {
+	tx *gorm.DB
+}
+
+func (r *gormSLRepositories) Orders() ports.OrderRepository {
+	return &GormSLOrderRepository{db: r.tx}
+}
+
+func (r *gormSLRepositories) Products() ports.ProductRepository {
+	return &GormSLProductRepository{db: r.tx}
+}
+
+func (r *gormSLRepositories) Inventory() ports.InventoryRepository {
+	return &GormSLInventoryRepository{db: r.tx}
+}
+
+func (r *gormSLRepositories) Payments() ports.PaymentRepository {
+	return &GormSLPaymentRepository{db: r.tx}
+}
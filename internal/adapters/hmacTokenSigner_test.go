@@ -0,0 +1,68 @@
+package adapters
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+func TestHMACTokenSignerVerify(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	secret := []byte("token-secret-of-at-least-32-bytes")
+
+	tests := []struct {
+		name        string
+		subject     string
+		elapsed     time.Duration
+		token       func(token string) string
+		signer      *HMACTokenSigner
+		wantSubject string
+		wantErr     error
+	}{
+		{name: "fresh", subject: "customer:1", wantSubject: "customer:1"},
+		{name: "subject with separator", subject: "a|b", wantSubject: "a|b"},
+		{name: "just before expiry", subject: "customer:1", elapsed: time.Hour - time.Second, wantSubject: "customer:1"},
+		{name: "at expiry", subject: "customer:1", elapsed: time.Hour, wantErr: domain.ErrInvalidToken},
+		{name: "after expiry", subject: "customer:1", elapsed: 2 * time.Hour, wantErr: domain.ErrInvalidToken},
+		{
+			name:    "tampered payload",
+			subject: "customer:1",
+			token: func(token string) string {
+				return "Y3VzdG9tZXI6MnwxODAwMDAwMDAw" + token[strings.Index(token, "."):]
+			},
+			wantErr: domain.ErrInvalidToken,
+		},
+		{name: "no signature", subject: "customer:1", token: func(token string) string { return strings.Split(token, ".")[0] }, wantErr: domain.ErrInvalidToken},
+		{name: "other secret", subject: "customer:1", signer: NewHMACTokenSigner([]byte("some-other-secret-of-32-bytes..."), nil), wantErr: domain.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(start)
+			signer := NewHMACTokenSigner(secret, clock)
+			if tt.signer != nil {
+				signer = tt.signer
+			}
+
+			token, err := signer.Sign(tt.subject, clock.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+			clock.Advance(tt.elapsed)
+
+			subject, err := NewHMACTokenSigner(secret, clock).Verify(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", subject, tt.wantSubject)
+			}
+		})
+	}
+}
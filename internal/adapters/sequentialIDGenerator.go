@@ -0,0 +1,29 @@
+package adapters
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// SequentialIDGenerator hands out predictable IDs counting up from 1, the
+// first being 00000000-0000-0000-0000-000000000001.
+type SequentialIDGenerator struct {
+	mu   sync.Mutex
+	next uint64
+}
+
+func NewSequentialIDGenerator() *SequentialIDGenerator {
+	return &SequentialIDGenerator{next: 1}
+}
+
+func (g *SequentialIDGenerator) NewID() uuid.UUID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[8:], g.next)
+	g.next++
+	return id
+}
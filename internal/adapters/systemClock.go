@@ -0,0 +1,18 @@
+package adapters
+
+import "time"
+
+// SystemClock is the clock of the machine.
+type SystemClock struct{}
+
+func NewSystemClock() *SystemClock {
+	return &SystemClock{}
+}
+
+func (c *SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
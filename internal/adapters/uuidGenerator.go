@@ -0,0 +1,14 @@
+package adapters
+
+import "github.com/google/uuid"
+
+// UUIDGenerator generates random version 4 UUIDs.
+type UUIDGenerator struct{}
+
+func NewUUIDGenerator() *UUIDGenerator {
+	return &UUIDGenerator{}
+}
+
+func (g *UUIDGenerator) NewID() uuid.UUID {
+	return uuid.New()
+}
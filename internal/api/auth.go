@@ -0,0 +1,45 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// principalKey is the key of the authenticated principal in the locals of a
+// request.
+const principalKey = "principal"
+
+// RequireRoles only lets requests through that carry the API key of a
+// principal with one of roles, as in "Authorization: Bearer cck_...".
+func RequireRoles(authService *application.AuthService, roles ...domain.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scheme, key, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return domain.ErrMissingAPIKey
+		}
+
+		principal, err := authService.Authenticate(c.UserContext(), strings.TrimSpace(key))
+		if err != nil {
+			return err
+		}
+		if !principal.HasAnyRole(roles...) {
+			return domain.Errorf(domain.ErrForbidden, "%s does not have any of the roles %v", principal.Name, roles)
+		}
+
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+}
+
+// actor returns the name recorded for changes made by the request, which is
+// the name of the authenticated principal.
+func actor(c *fiber.Ctx) string {
+	principal, ok := c.Locals(principalKey).(*domain.Principal)
+	if !ok {
+		return domain.ActorStaff
+	}
+	return principal.Name
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type AuthHandler struct {
+	authService *application.AuthService
+}
+
+func NewAuthHandler(authService *application.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+	}
+}
+
+func (h *AuthHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var input domain.CreateAPIKeyInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	apiKey, err := h.authService.CreateAPIKey(c.UserContext(), input)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(apiKey)
+}
+
+func (h *AuthHandler) GetAPIKeys(c *fiber.Ctx) error {
+	apiKeys, err := h.authService.ListAPIKeys(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(apiKeys)
+}
+
+func (h *AuthHandler) DeleteAPIKey(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := h.authService.DeleteAPIKey(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// customerIDKey is the key of the ID of the logged in customer in the locals
+// of a request.
+const customerIDKey = "customer_id"
+
+// RequireCustomer only lets requests through that carry the token a customer
+// got when logging in, as in "Authorization: Bearer ...".
+func RequireCustomer(customerService *application.CustomerService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scheme, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return domain.ErrMissingCustomerToken
+		}
+
+		customerID, err := customerService.Authenticate(c.UserContext(), strings.TrimSpace(token))
+		if err != nil {
+			return err
+		}
+
+		c.Locals(customerIDKey, customerID)
+		return c.Next()
+	}
+}
+
+// Login attempts allowed per client and email within loginWindow, and per
+// email from all clients together within loginEmailWindow.
+const (
+	loginAttempts      = 10
+	loginWindow        = time.Minute
+	loginEmailAttempts = 50
+	loginEmailWindow   = 15 * time.Minute
+)
+
+// LimitLogins lets a client make at most max login attempts for one email per
+// window, so that passwords can't be guessed at speed. Clients are told apart
+// by IP.
+func LimitLogins(max int, window time.Duration) fiber.Handler {
+	return limitLogins(max, window, func(c *fiber.Ctx) string {
+		return c.IP() + "|" + loginEmail(c)
+	})
+}
+
+// LimitLoginsPerEmail lets all clients together make at most max login
+// attempts for one email per window, so that guessing a password from many
+// IPs is slowed down as well.
+func LimitLoginsPerEmail(max int, window time.Duration) fiber.Handler {
+	return limitLogins(max, window, loginEmail)
+}
+
+func limitLogins(max int, window time.Duration, key func(c *fiber.Ctx) string) fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:          max,
+		Expiration:   window,
+		KeyGenerator: key,
+		LimitReached: func(c *fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusTooManyRequests, "too many login attempts, try again later")
+		},
+	})
+}
+
+// loginEmail returns the email a login request is for, as it is looked up.
+// Requests without one share the empty email, they fail validation anyway.
+func loginEmail(c *fiber.Ctx) string {
+	var input domain.LoginCustomerInput
+	_ = json.Unmarshal(c.Body(), &input)
+	return domain.NormalizeEmail(input.Email)
+}
+
+// customerID returns the ID of the customer authenticated by RequireCustomer.
+func customerID(c *fiber.Ctx) uuid.UUID {
+	id, _ := c.Locals(customerIDKey).(uuid.UUID)
+	return id
+}
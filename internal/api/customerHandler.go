@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type CustomerHandler struct {
+	customerService *application.CustomerService
+	orderService    *application.OrderService
+}
+
+func NewCustomerHandler(customerService *application.CustomerService, orderService *application.OrderService) *CustomerHandler {
+	return &CustomerHandler{
+		customerService: customerService,
+		orderService:    orderService,
+	}
+}
+
+func (h *CustomerHandler) RegisterCustomer(c *fiber.Ctx) error {
+	var input domain.RegisterCustomerInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	customer, err := h.customerService.RegisterCustomer(c.UserContext(), input)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(customer)
+}
+
+func (h *CustomerHandler) Login(c *fiber.Ctx) error {
+	var input domain.LoginCustomerInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	login, err := h.customerService.Login(c.UserContext(), input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(login)
+}
+
+// SessionLogin logs a customer in from a storefront session and makes the
+// cart of the session the cart of the customer. A session whose order was
+// already checked out, or whose cart belongs to another customer, is left
+// alone and the customer is logged in without it.
+func (h *CustomerHandler) SessionLogin(c *fiber.Ctx) error {
+	sessionId := c.Params("id")
+	var input domain.LoginCustomerInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	login, err := h.customerService.Login(c.UserContext(), input)
+	if err != nil {
+		return err
+	}
+
+	login.Order, err = h.orderService.AssignSessionOrderToCustomer(c.UserContext(), sessionId, login.Customer.ID)
+	if err != nil && !errors.Is(err, domain.ErrOrderNotOpen) && !errors.Is(err, domain.ErrCartOfOtherCustomer) {
+		return err
+	}
+
+	return c.JSON(login)
+}
+
+func (h *CustomerHandler) GetCustomer(c *fiber.Ctx) error {
+	customer, err := h.customerService.GetCustomer(c.UserContext(), customerID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(customer)
+}
+
+func (h *CustomerHandler) GetCustomerOrders(c *fiber.Ctx) error {
+	query, err := parseOrderQuery(c)
+	if err != nil {
+		return err
+	}
+
+	orders, err := h.orderService.ListCustomerOrders(c.UserContext(), customerID(c), query)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orders)
+}
+
+func (h *CustomerHandler) GetAddresses(c *fiber.Ctx) error {
+	addresses, err := h.customerService.GetAddresses(c.UserContext(), customerID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(addresses)
+}
+
+func (h *CustomerHandler) CreateAddress(c *fiber.Ctx) error {
+	var input domain.CreateAddressInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	address, err := h.customerService.CreateAddress(c.UserContext(), customerID(c), input)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(address)
+}
+
+func (h *CustomerHandler) UpdateAddress(c *fiber.Ctx) error {
+	id := c.Params("addressId")
+	var input domain.UpdateAddressInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	address, err := h.customerService.UpdateAddress(c.UserContext(), customerID(c), id, input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(address)
+}
+
+func (h *CustomerHandler) DeleteAddress(c *fiber.Ctx) error {
+	id := c.Params("addressId")
+	err := h.customerService.DeleteAddress(c.UserContext(), customerID(c), id)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSessionLoginToCartOfOtherCustomer(t *testing.T) {
+	ctx := context.Background()
+	logger := adapters.NewLogrusLogger()
+	logger.SetLogLevel("fatal")
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "commerce.db")+"?_txlock=immediate"), &gorm.Config{
+		TranslateError: true,
+		Logger:         gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderRepository, err := adapters.NewGormSLOrderRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	productRepository, err := adapters.NewGormSLProductRepository(db, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inventoryRepository, err := adapters.NewGormSLInventoryRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paymentRepository, err := adapters.NewGormSLPaymentRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	customerRepository, err := adapters.NewGormSLCustomerRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priceCalculator, err := domain.NewPriceCalculator(25, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := adapters.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+	idGenerator := adapters.NewSequentialIDGenerator()
+	orderService := application.NewOrderService(adapters.NewGormSLUnitOfWork(db), orderRepository, productRepository, inventoryRepository, paymentRepository,
+		adapters.NewFakePaymentProvider([]byte("test-callback-secret-of-32-bytes")), priceCalculator, 10*time.Minute, 30*time.Minute, clock, idGenerator, logger)
+	customerService := application.NewCustomerService(customerRepository, adapters.NewBcryptPasswordHasher(),
+		adapters.NewHMACTokenSigner([]byte("test-token-secret-of-32-bytes..."), clock), time.Hour, clock, idGenerator, logger)
+
+	anna, err := customerService.RegisterCustomer(ctx, domain.RegisterCustomerInput{Email: "anna@example.com", Name: "Anna", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := customerService.RegisterCustomer(ctx, domain.RegisterCustomerInput{Email: "bertil@example.com", Name: "Bertil", Password: "battery staple"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anna logged in on a shared computer and left her cart in the session
+	sessionId := uuid.New()
+	if _, err := orderService.CreateSessionOrder(ctx, sessionId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orderService.AssignSessionOrderToCustomer(ctx, sessionId.String(), anna.Customer.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler(logger)})
+	app.Post("/sessions/:id/login", NewCustomerHandler(customerService, orderService).SessionLogin)
+
+	req := httptest.NewRequest(fiber.MethodPost, "/sessions/"+sessionId.String()+"/login", strings.NewReader(`{"email": "bertil@example.com", "password": "battery staple"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	var login application.DTOCustomerLogin
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		t.Fatal(err)
+	}
+	if login.Token == "" || login.Order != nil {
+		t.Errorf("login has token %q and order %v, want a token and no order", login.Token, login.Order)
+	}
+
+	cart, err := orderService.GetOrderDetailsBySessionId(ctx, sessionId.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cart.Order.CustomerID == nil || *cart.Order.CustomerID != anna.Customer.ID {
+		t.Errorf("cart customer = %v, want %s", cart.Order.CustomerID, anna.Customer.ID)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
+)
+
+func TestLimitLogins(t *testing.T) {
+	logger := adapters.NewLogrusLogger()
+	logger.SetLogLevel("fatal")
+
+	// Clients are told apart by the forwarded IP, as behind a proxy
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler(logger), ProxyHeader: fiber.HeaderXForwardedFor})
+	limits := []fiber.Handler{LimitLogins(2, time.Minute), LimitLoginsPerEmail(3, time.Minute)}
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Post("/customers/login", append(limits, ok)...)
+	app.Post("/sessions/:id/login", append(limits, ok)...)
+
+	tests := []struct {
+		name       string
+		path       string
+		ip         string
+		email      string
+		wantStatus int
+	}{
+		{name: "first attempt", path: "/customers/login", ip: "10.0.0.1", email: "anna@example.com", wantStatus: fiber.StatusOK},
+		{name: "second attempt on the other endpoint", path: "/sessions/1/login", ip: "10.0.0.1", email: "Anna@Example.com", wantStatus: fiber.StatusOK},
+		{name: "third attempt from the client", path: "/customers/login", ip: "10.0.0.1", email: "anna@example.com", wantStatus: fiber.StatusTooManyRequests},
+		{name: "other email from the client", path: "/customers/login", ip: "10.0.0.1", email: "bertil@example.com", wantStatus: fiber.StatusOK},
+		{name: "third attempt for the email from another client", path: "/customers/login", ip: "10.0.0.2", email: "anna@example.com", wantStatus: fiber.StatusOK},
+		{name: "fourth attempt for the email from a third client", path: "/sessions/1/login", ip: "10.0.0.3", email: "anna@example.com", wantStatus: fiber.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodPost, tt.path, strings.NewReader(`{"email": "`+tt.email+`", "password": "guess"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderXForwardedFor, tt.ip)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+		if tt.wantStatus == fiber.StatusTooManyRequests && resp.Header.Get(fiber.HeaderRetryAfter) == "" {
+			t.Errorf("%s: no Retry-After header", tt.name)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+)
+
+// problem is a problem details body as described in RFC 7807.
+type problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail,omitempty"`
+	Instance  string             `json:"instance"`
+	RequestID string             `json:"request_id,omitempty"`
+	Errors    []domain.Violation `json:"errors,omitempty"`
+}
+
+// errorHandler responds to errors returned by the handlers with a
+// problem+json body and the status that matches the kind of error. Details of
+// internal errors are logged but not sent to the client.
+func errorHandler(logger ports.Logger) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		status := errorStatus(err)
+		requestID, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+
+		body := problem{
+			Type:      "about:blank",
+			Title:     utils.StatusMessage(status),
+			Status:    status,
+			Detail:    err.Error(),
+			Instance:  c.OriginalURL(),
+			RequestID: requestID,
+		}
+
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			body.Detail = "one or more fields are invalid"
+			body.Errors = validationErr.Violations
+		}
+
+		if status >= fiber.StatusInternalServerError {
+			logger.Error("request failed", map[string]interface{}{
+				"error":      err,
+				"method":     c.Method(),
+				"path":       c.Path(),
+				"request_id": requestID,
+			})
+			body.Detail = "the request could not be completed, refer to the request ID when reporting this"
+		}
+
+		if status == fiber.StatusUnauthorized {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		}
+
+		return c.Status(status).JSON(body, "application/problem+json")
+	}
+}
+
+func errorStatus(err error) int {
+	var fiberErr *fiber.Error
+	switch {
+	case errors.Is(err, domain.ErrValidation):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrInvalidInput):
+		return fiber.StatusBadRequest
+	case errors.Is(err, domain.ErrNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return fiber.StatusConflict
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, domain.ErrForbidden):
+		return fiber.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return fiber.StatusServiceUnavailable
+	case errors.As(err, &fiberErr):
+		return fiberErr.Code
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// parseBody parses the request body into out.
+func parseBody(c *fiber.Ctx, out interface{}) error {
+	if err := c.BodyParser(out); err != nil {
+		return domain.Errorf(domain.ErrInvalidInput, "invalid request body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+)
+
+type JobHandler struct {
+	scheduler *application.Scheduler
+}
+
+func NewJobHandler(scheduler *application.Scheduler) *JobHandler {
+	return &JobHandler{
+		scheduler: scheduler,
+	}
+}
+
+func (h *JobHandler) GetJobs(c *fiber.Ctx) error {
+	jobs, err := h.scheduler.ListJobs(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(jobs)
+}
+
+// RunJob starts a run of the job and responds before it finishes.
+func (h *JobHandler) RunJob(c *fiber.Ctx) error {
+	name := c.Params("name")
+	job, err := h.scheduler.Trigger(c.UserContext(), name)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+func (h *JobHandler) GetJobRuns(c *fiber.Ctx) error {
+	name := c.Params("name")
+	page, err := parsePage(c)
+	if err != nil {
+		return err
+	}
+
+	runs, err := h.scheduler.GetJobRuns(c.UserContext(), name, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(runs)
+}
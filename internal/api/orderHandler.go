@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/morgansundqvist/service-composable-commerce/internal/application"
@@ -8,40 +10,50 @@ import (
 )
 
 type OrderHandler struct {
-	orderService *application.OrderService
+	orderService   *application.OrderService
+	sessionService *application.SessionService
 }
 
-func NewOrderHandler(orderService *application.OrderService) *OrderHandler {
+func NewOrderHandler(orderService *application.OrderService, sessionService *application.SessionService) *OrderHandler {
 	return &OrderHandler{
-		orderService: orderService,
+		orderService:   orderService,
+		sessionService: sessionService,
 	}
 }
 
 func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 	var input domain.CreateOrderInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	order, err := h.orderService.CreateOrder(input)
+	order, err := h.orderService.CreateOrder(c.UserContext(), input)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(order)
 }
 
+func (h *OrderHandler) GetOrders(c *fiber.Ctx) error {
+	query, err := parseOrderQuery(c)
+	if err != nil {
+		return err
+	}
+
+	orders, err := h.orderService.ListOrders(c.UserContext(), query)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orders)
+}
+
 func (h *OrderHandler) GetOrderByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	order, err := h.orderService.GetOrderById(id)
+	order, err := h.orderService.GetOrderById(c.UserContext(), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(order)
@@ -49,23 +61,13 @@ func (h *OrderHandler) GetOrderByID(c *fiber.Ctx) error {
 
 func (h *OrderHandler) GetOrderDetailsBySessionId(c *fiber.Ctx) error {
 	sessionId := c.Params("id")
-	orderDetails, err := h.orderService.GetOrderDetailsBySessionId(sessionId)
+	orderDetails, err := h.orderService.GetOrderDetailsBySessionId(c.UserContext(), sessionId)
 	if err != nil {
-		if err.Error() == "record not found" {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "did not find order connected to session",
-			})
-		}
-
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
-	if orderDetails.Order.Status != "created" {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "did not find order with created status connected to session",
-		})
+	if orderDetails.Order.Status != domain.OrderStatusCreated {
+		return domain.NewError(domain.ErrNotFound, "did not find order with created status connected to session")
 	}
 
 	return c.JSON(orderDetails)
@@ -74,17 +76,13 @@ func (h *OrderHandler) GetOrderDetailsBySessionId(c *fiber.Ctx) error {
 func (h *OrderHandler) UpdateOrder(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var input domain.UpdateOrderInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	order, err := h.orderService.UpdateOrder(id, input)
+	order, err := h.orderService.UpdateOrder(c.UserContext(), id, input)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(order)
@@ -92,11 +90,9 @@ func (h *OrderHandler) UpdateOrder(c *fiber.Ctx) error {
 
 func (h *OrderHandler) DeleteOrder(c *fiber.Ctx) error {
 	id := c.Params("id")
-	err := h.orderService.DeleteOrder(id)
+	err := h.orderService.DeleteOrder(c.UserContext(), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -106,18 +102,172 @@ func (h *OrderHandler) CreateSessionOrder(c *fiber.Ctx) error {
 	sessionId := c.Params("id")
 	uuidSessionId, err := uuid.Parse(sessionId)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return domain.Errorf(domain.ErrInvalidInput, "invalid session ID %q", sessionId)
+	}
 
+	order, err := h.orderService.CreateSessionOrder(c.UserContext(), uuidSessionId)
+	if err != nil {
+		return err
 	}
 
-	order, err := h.orderService.CreateSessionOrder(uuidSessionId)
+	sessionToken, err := h.sessionService.IssueToken(c.UserContext(), uuidSessionId)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
+	setSessionToken(c, sessionToken)
 
 	return c.Status(fiber.StatusCreated).JSON(order)
 }
+
+func (h *OrderHandler) transitionOrder(c *fiber.Ctx, transition func(ctx context.Context, id string, actor string) (*domain.Order, error)) error {
+	id := c.Params("id")
+	order, err := transition(c.UserContext(), id, actor(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(order)
+}
+
+func (h *OrderHandler) CheckoutOrder(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var input domain.CheckoutInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	orderDetails, err := h.orderService.CheckoutOrder(c.UserContext(), id, input, actor(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orderDetails)
+}
+
+func (h *OrderHandler) CheckoutSessionOrder(c *fiber.Ctx) error {
+	sessionId := c.Params("id")
+	var input domain.CheckoutInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	orderDetails, err := h.orderService.CheckoutSessionOrder(c.UserContext(), sessionId, input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orderDetails)
+}
+
+func (h *OrderHandler) CancelOrder(c *fiber.Ctx) error {
+	return h.transitionOrder(c, h.orderService.CancelOrder)
+}
+
+func (h *OrderHandler) StartOrderProduction(c *fiber.Ctx) error {
+	return h.transitionOrder(c, h.orderService.StartOrderProduction)
+}
+
+func (h *OrderHandler) ShipOrder(c *fiber.Ctx) error {
+	return h.transitionOrder(c, h.orderService.ShipOrder)
+}
+
+func (h *OrderHandler) DeliverOrder(c *fiber.Ctx) error {
+	return h.transitionOrder(c, h.orderService.DeliverOrder)
+}
+
+func (h *OrderHandler) RefundOrder(c *fiber.Ctx) error {
+	return h.transitionOrder(c, h.orderService.RefundOrder)
+}
+
+func (h *OrderHandler) GetOrderStatusTransitions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	transitions, err := h.orderService.GetOrderStatusTransitions(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"transitions": transitions,
+	})
+}
+
+func (h *OrderHandler) StartSessionPayment(c *fiber.Ctx) error {
+	sessionId := c.Params("id")
+	paymentDetails, err := h.orderService.StartSessionPayment(c.UserContext(), sessionId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(paymentDetails)
+}
+
+func (h *OrderHandler) GetOrderPayments(c *fiber.Ctx) error {
+	id := c.Params("id")
+	payments, err := h.orderService.GetOrderPayments(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(payments)
+}
+
+func (h *OrderHandler) HandlePaymentCallback(c *fiber.Ctx) error {
+	provider := c.Params("provider")
+
+	headers := make(map[string]string)
+	for key, values := range c.GetReqHeaders() {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+
+	err := h.orderService.HandlePaymentCallback(c.UserContext(), provider, c.Body(), headers)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *OrderHandler) AddSessionOrderLine(c *fiber.Ctx) error {
+	sessionId := c.Params("id")
+	var input domain.AddOrderLineInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	orderDetails, err := h.orderService.AddSessionOrderLine(c.UserContext(), sessionId, input)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(orderDetails)
+}
+
+func (h *OrderHandler) UpdateSessionOrderLine(c *fiber.Ctx) error {
+	sessionId := c.Params("id")
+	orderLineId := c.Params("lineId")
+	var input domain.UpdateOrderLineQuantityInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	orderDetails, err := h.orderService.UpdateSessionOrderLineQuantity(c.UserContext(), sessionId, orderLineId, input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orderDetails)
+}
+
+func (h *OrderHandler) DeleteSessionOrderLine(c *fiber.Ctx) error {
+	sessionId := c.Params("id")
+	orderLineId := c.Params("lineId")
+
+	orderDetails, err := h.orderService.RemoveSessionOrderLine(c.UserContext(), sessionId, orderLineId)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(orderDetails)
+}
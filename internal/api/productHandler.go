@@ -19,28 +19,27 @@ func NewProductHandler(productService *application.ProductService) *ProductHandl
 
 func (h *ProductHandler) CreateProductGroup(c *fiber.Ctx) error {
 	var input domain.CreateProductGroupInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	productGroup, err := h.productService.CreateProductGroup(input)
+	productGroup, err := h.productService.CreateProductGroup(c.UserContext(), input)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(productGroup)
 }
 
 func (h *ProductHandler) GetProductGroups(c *fiber.Ctx) error {
-	productGroups, err := h.productService.GetProductGroups()
+	query, err := parseProductGroupQuery(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
+	}
+
+	productGroups, err := h.productService.GetProductGroups(c.UserContext(), query)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(productGroups)
@@ -48,40 +47,87 @@ func (h *ProductHandler) GetProductGroups(c *fiber.Ctx) error {
 
 func (h *ProductHandler) GetProductGroupByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	productGroup, err := h.productService.GetProductGroupByID(id)
+	productGroup, err := h.productService.GetProductGroupByID(c.UserContext(), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(productGroup)
 }
 
+func (h *ProductHandler) UpdateProductGroup(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var input domain.UpdateProductGroupInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	productGroup, err := h.productService.UpdateProductGroup(c.UserContext(), id, input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(productGroup)
+}
+
+func (h *ProductHandler) DeleteProductGroup(c *fiber.Ctx) error {
+	id := c.Params("id")
+	input := domain.DeleteProductGroupInput{
+		Cascade: c.QueryBool("cascade"),
+	}
+	if reassignTo := c.Query("reassign_to"); reassignTo != "" {
+		uuidReassignTo, err := uuid.Parse(reassignTo)
+		if err != nil {
+			return domain.Errorf(domain.ErrInvalidInput, "invalid reassign_to ID %q", reassignTo)
+		}
+		input.ReassignTo = &uuidReassignTo
+	}
+
+	err := h.productService.DeleteProductGroup(c.UserContext(), id, input)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *ProductHandler) ReorderProductGroups(c *fiber.Ctx) error {
+	var input domain.ReorderProductGroupsInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	productGroups, err := h.productService.ReorderProductGroups(c.UserContext(), input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(productGroups)
+}
+
 func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 	var input domain.CreateProductInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	product, err := h.productService.CreateProduct(input)
+	product, err := h.productService.CreateProduct(c.UserContext(), input)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(product)
 }
 
 func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
-	products, err := h.productService.ListProducts()
+	query, err := parseProductQuery(c)
+	if err != nil {
+		return err
+	}
+
+	products, err := h.productService.ListProducts(c.UserContext(), query)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(products)
@@ -89,11 +135,9 @@ func (h *ProductHandler) GetProducts(c *fiber.Ctx) error {
 
 func (h *ProductHandler) GetProductByID(c *fiber.Ctx) error {
 	id := c.Params("id")
-	product, err := h.productService.GetProductByID(id)
+	product, err := h.productService.GetProductByID(c.UserContext(), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(product)
@@ -102,17 +146,13 @@ func (h *ProductHandler) GetProductByID(c *fiber.Ctx) error {
 func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var input domain.UpdateProductInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := parseBody(c, &input); err != nil {
+		return err
 	}
 
-	product, err := h.productService.UpdateProduct(id, input)
+	product, err := h.productService.UpdateProduct(c.UserContext(), id, input)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(product)
@@ -120,11 +160,9 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 
 func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
-	err := h.productService.DeleteProduct(id)
+	err := h.productService.DeleteProduct(c.UserContext(), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -134,16 +172,53 @@ func (h *ProductHandler) GetProductsByProductGroupID(c *fiber.Ctx) error {
 	id := c.Params("id")
 	uuidId, err := uuid.Parse(id)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return domain.Errorf(domain.ErrInvalidInput, "invalid ID %q", id)
 	}
-	products, err := h.productService.GetProductsByProductGroupID(uuidId)
+
+	query, err := parseProductQuery(c)
+	if err != nil {
+		return err
+	}
+
+	products, err := h.productService.GetProductsByProductGroupID(c.UserContext(), uuidId, query)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(products)
 }
+
+func (h *ProductHandler) GetProductStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+	stock, err := h.productService.GetProductStock(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(stock)
+}
+
+func (h *ProductHandler) SetProductStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var input domain.SetStockLevelInput
+	if err := parseBody(c, &input); err != nil {
+		return err
+	}
+
+	stock, err := h.productService.SetProductStock(c.UserContext(), id, input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(stock)
+}
+
+func (h *ProductHandler) DeleteProductStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := h.productService.DeleteProductStock(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// queryInt parses an optional integer query parameter.
+func queryInt(c *fiber.Ctx, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, domain.Errorf(domain.ErrInvalidInput, "query parameter %s must be an integer", name)
+	}
+	return &parsed, nil
+}
+
+// queryBool parses an optional boolean query parameter.
+func queryBool(c *fiber.Ctx, name string) (*bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, domain.Errorf(domain.ErrInvalidInput, "query parameter %s must be true or false", name)
+	}
+	return &parsed, nil
+}
+
+// queryUUID parses an optional UUID query parameter.
+func queryUUID(c *fiber.Ctx, name string) (*uuid.UUID, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := uuid.Parse(value)
+	if err != nil {
+		return nil, domain.Errorf(domain.ErrInvalidInput, "query parameter %s must be a UUID", name)
+	}
+	return &parsed, nil
+}
+
+// queryTime parses an optional time query parameter given as RFC 3339 or as a
+// date, which means midnight local time.
+func queryTime(c *fiber.Ctx, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		parsed, err = time.ParseInLocation(time.DateOnly, value, time.Local)
+	}
+	if err != nil {
+		return nil, domain.Errorf(domain.ErrInvalidInput, "query parameter %s must be a date or an RFC 3339 time", name)
+	}
+	return &parsed, nil
+}
+
+// parsePage parses the offset and limit query parameters.
+func parsePage(c *fiber.Ctx) (domain.Page, error) {
+	var page domain.Page
+
+	offset, err := queryInt(c, "offset")
+	if err != nil {
+		return page, err
+	}
+	if offset != nil {
+		page.Offset = *offset
+	}
+
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		return page, err
+	}
+	if limit != nil {
+		page.Limit = *limit
+	}
+
+	return page, nil
+}
+
+// parseProductQuery parses the filters, sort and page of a product listing.
+func parseProductQuery(c *fiber.Ctx) (domain.ProductQuery, error) {
+	var query domain.ProductQuery
+	var err error
+
+	if query.ProductGroupID, err = queryUUID(c, "product_group_id"); err != nil {
+		return query, err
+	}
+	if query.IsSold, err = queryBool(c, "is_sold"); err != nil {
+		return query, err
+	}
+	if query.IsConfigurable, err = queryBool(c, "is_configurable"); err != nil {
+		return query, err
+	}
+	if query.IsSoldSeparately, err = queryBool(c, "is_sold_separately"); err != nil {
+		return query, err
+	}
+	if query.MinPrice, err = queryInt(c, "min_price"); err != nil {
+		return query, err
+	}
+	if query.MaxPrice, err = queryInt(c, "max_price"); err != nil {
+		return query, err
+	}
+	if query.Sort, err = domain.ParseSort(c.Query("sort"), domain.DefaultSort, domain.ProductSortFields...); err != nil {
+		return query, err
+	}
+	if query.Page, err = parsePage(c); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// parseProductGroupQuery parses the filters, sort and page of a product group
+// listing.
+func parseProductGroupQuery(c *fiber.Ctx) (domain.ProductGroupQuery, error) {
+	var query domain.ProductGroupQuery
+	var err error
+
+	if query.IsSold, err = queryBool(c, "is_sold"); err != nil {
+		return query, err
+	}
+	if query.Sort, err = domain.ParseSort(c.Query("sort"), domain.DefaultSort, domain.ProductGroupSortFields...); err != nil {
+		return query, err
+	}
+	if query.Page, err = parsePage(c); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// parseOrderQuery parses the filters, search, sort and page of an order
+// listing. Several statuses are separated by commas.
+func parseOrderQuery(c *fiber.Ctx) (domain.OrderQuery, error) {
+	query := domain.OrderQuery{
+		Email:       c.Query("email"),
+		ZipCode:     c.Query("zip_code"),
+		CompanyName: c.Query("company_name"),
+		Search:      c.Query("q"),
+	}
+	var err error
+
+	if statuses := c.Query("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			query.Statuses = append(query.Statuses, domain.OrderStatus(strings.TrimSpace(status)))
+		}
+	}
+	if query.CreatedFrom, err = queryTime(c, "created_from"); err != nil {
+		return query, err
+	}
+	if query.CreatedBefore, err = queryTime(c, "created_before"); err != nil {
+		return query, err
+	}
+	if query.Sort, err = domain.ParseSort(c.Query("sort"), domain.DefaultOrderSort, domain.OrderSortFields...); err != nil {
+		return query, err
+	}
+	if query.Page, err = parsePage(c); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
@@ -1,22 +1,33 @@
 package api
 
 import (
+	"context"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/template/html/v2"
 	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
 	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
 )
 
 func SetupRouter(
 	productService *application.ProductService,
 	orderService *application.OrderService,
+	authService *application.AuthService,
+	sessionService *application.SessionService,
+	customerService *application.CustomerService,
+	scheduler *application.Scheduler,
 	engine *html.Engine,
+	requestTimeout time.Duration,
 	logger ports.Logger) *fiber.App {
 
 	app := fiber.New(
 		fiber.Config{
-			Views:       engine,
-			ViewsLayout: "layouts/base",
+			Views:        engine,
+			ViewsLayout:  "layouts/base",
+			ErrorHandler: errorHandler(logger),
 		},
 	)
 
@@ -31,33 +42,104 @@ func SetupRouter(
 	// 	return c.Next()
 	// })
 
+	// Every request gets an ID that is echoed in the X-Request-ID header and
+	// in error responses
+	app.Use(requestid.New())
+
+	// Queries of a request are cancelled once it runs past its timeout
+	app.Use(func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), requestTimeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	})
+
 	productHandler := NewProductHandler(productService)
-	orderHandler := NewOrderHandler(orderService)
+	orderHandler := NewOrderHandler(orderService, sessionService)
+	authHandler := NewAuthHandler(authService)
+	customerHandler := NewCustomerHandler(customerService, orderService)
+	jobHandler := NewJobHandler(scheduler)
 	viewHandler := NewViewHandler(productService, orderService, logger)
 
 	app.Get("/", viewHandler.HomePage)
 
 	api := app.Group("/api")
 
-	api.Post("/product-groups", productHandler.CreateProductGroup)
+	// The catalog can be read by anyone but only changed by catalog editors,
+	// orders are only handled by fulfilment. The session endpoints used by the
+	// storefront only need the token handed out when the session was created,
+	// the customer endpoints the token handed out at login, and the payment
+	// callbacks are public.
+	catalogEditor := RequireRoles(authService, domain.RoleCatalogEditor)
+	fulfilment := RequireRoles(authService, domain.RoleFulfilment)
+	admin := RequireRoles(authService, domain.RoleAdmin)
+	sessionOwner := RequireSessionToken(sessionService)
+	customer := RequireCustomer(customerService)
+	// Both login endpoints share the limits
+	loginLimit := LimitLogins(loginAttempts, loginWindow)
+	loginEmailLimit := LimitLoginsPerEmail(loginEmailAttempts, loginEmailWindow)
+
+	api.Post("/product-groups", catalogEditor, productHandler.CreateProductGroup)
 	api.Get("/product-groups", productHandler.GetProductGroups)
+	api.Put("/product-groups/order", catalogEditor, productHandler.ReorderProductGroups)
 	api.Get("/product-groups/:id", productHandler.GetProductGroupByID)
+	api.Patch("/product-groups/:id", catalogEditor, productHandler.UpdateProductGroup)
+	api.Delete("/product-groups/:id", catalogEditor, productHandler.DeleteProductGroup)
 	api.Get("/product-groups/:id/products", productHandler.GetProductsByProductGroupID)
 
-	api.Post("/products", productHandler.CreateProduct)
+	api.Post("/products", catalogEditor, productHandler.CreateProduct)
 	api.Get("/products", productHandler.GetProducts)
 	api.Get("/products/:id", productHandler.GetProductByID)
-	api.Patch("/products/:id", productHandler.UpdateProduct)
-	api.Delete("/products/:id", productHandler.DeleteProduct)
+	api.Patch("/products/:id", catalogEditor, productHandler.UpdateProduct)
+	api.Delete("/products/:id", catalogEditor, productHandler.DeleteProduct)
+	api.Get("/products/:id/stock", catalogEditor, productHandler.GetProductStock)
+	api.Put("/products/:id/stock", catalogEditor, productHandler.SetProductStock)
+	api.Delete("/products/:id/stock", catalogEditor, productHandler.DeleteProductStock)
 
-	api.Post("/orders", orderHandler.CreateOrder)
-	//api.Get("/orders", orderHandler.GetOrders) Maybe add later if needed
-	api.Get("/orders/:id", orderHandler.GetOrderByID)
-	api.Patch("/orders/:id", orderHandler.UpdateOrder)
-	api.Delete("/orders/:id", orderHandler.DeleteOrder)
+	orders := api.Group("/orders", fulfilment)
+	orders.Post("/", orderHandler.CreateOrder)
+	orders.Get("/", orderHandler.GetOrders)
+	orders.Get("/:id", orderHandler.GetOrderByID)
+	orders.Patch("/:id", orderHandler.UpdateOrder)
+	orders.Delete("/:id", orderHandler.DeleteOrder)
+	orders.Get("/:id/transitions", orderHandler.GetOrderStatusTransitions)
+	orders.Post("/:id/checkout", orderHandler.CheckoutOrder)
+	orders.Post("/:id/cancel", orderHandler.CancelOrder)
+	orders.Post("/:id/start-production", orderHandler.StartOrderProduction)
+	orders.Post("/:id/ship", orderHandler.ShipOrder)
+	orders.Post("/:id/deliver", orderHandler.DeliverOrder)
+	orders.Post("/:id/refund", orderHandler.RefundOrder)
+	orders.Get("/:id/payments", orderHandler.GetOrderPayments)
 
 	api.Post("/sessions/:id", orderHandler.CreateSessionOrder)
-	api.Get("/sessions/:id/order", orderHandler.GetOrderDetailsBySessionId)
+	api.Get("/sessions/:id/order", sessionOwner, orderHandler.GetOrderDetailsBySessionId)
+	api.Post("/sessions/:id/order/lines", sessionOwner, orderHandler.AddSessionOrderLine)
+	api.Patch("/sessions/:id/order/lines/:lineId", sessionOwner, orderHandler.UpdateSessionOrderLine)
+	api.Delete("/sessions/:id/order/lines/:lineId", sessionOwner, orderHandler.DeleteSessionOrderLine)
+	api.Post("/sessions/:id/checkout", sessionOwner, orderHandler.CheckoutSessionOrder)
+	api.Post("/sessions/:id/payments", sessionOwner, orderHandler.StartSessionPayment)
+	api.Post("/sessions/:id/login", loginLimit, loginEmailLimit, sessionOwner, customerHandler.SessionLogin)
+
+	api.Post("/customers", customerHandler.RegisterCustomer)
+	api.Post("/customers/login", loginLimit, loginEmailLimit, customerHandler.Login)
+	me := api.Group("/customers/me", customer)
+	me.Get("/", customerHandler.GetCustomer)
+	me.Get("/orders", customerHandler.GetCustomerOrders)
+	me.Get("/addresses", customerHandler.GetAddresses)
+	me.Post("/addresses", customerHandler.CreateAddress)
+	me.Patch("/addresses/:addressId", customerHandler.UpdateAddress)
+	me.Delete("/addresses/:addressId", customerHandler.DeleteAddress)
+
+	api.Post("/payments/:provider/callback", orderHandler.HandlePaymentCallback)
+
+	adminAPI := api.Group("/admin", admin)
+	adminAPI.Post("/api-keys", authHandler.CreateAPIKey)
+	adminAPI.Get("/api-keys", authHandler.GetAPIKeys)
+	adminAPI.Delete("/api-keys/:id", authHandler.DeleteAPIKey)
+	adminAPI.Get("/jobs", jobHandler.GetJobs)
+	adminAPI.Post("/jobs/:name/run", jobHandler.RunJob)
+	adminAPI.Get("/jobs/:name/runs", jobHandler.GetJobRuns)
 
 	return app
 }
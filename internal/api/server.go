@@ -0,0 +1,24 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Serve runs app on address until ctx is cancelled, then stops accepting
+// connections and waits up to shutdownTimeout for requests in flight.
+func Serve(ctx context.Context, app *fiber.App, address string, shutdownTimeout time.Duration) error {
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(address)
+	}()
+
+	select {
+	case err := <-listenErr:
+		return err
+	case <-ctx.Done():
+		return app.ShutdownWithTimeout(shutdownTimeout)
+	}
+}
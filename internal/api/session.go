@@ -0,0 +1,45 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+)
+
+const (
+	sessionTokenCookie = "session_token"
+	sessionTokenHeader = "X-Session-Token"
+)
+
+// RequireSessionToken only lets requests through that carry the token of the
+// session in the path, either in the X-Session-Token header or in the cookie
+// set when the session was created.
+func RequireSessionToken(sessionService *application.SessionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Get(sessionTokenHeader)
+		if token == "" {
+			token = c.Cookies(sessionTokenCookie)
+		}
+
+		err := sessionService.VerifyToken(c.UserContext(), c.Params("id"), token)
+		if err != nil {
+			return err
+		}
+
+		return c.Next()
+	}
+}
+
+// setSessionToken hands a session token to the client. The cookie is scoped to
+// the session, so a browser can hold the tokens of several sessions.
+func setSessionToken(c *fiber.Ctx, sessionToken *application.DTOSessionToken) {
+	c.Set(sessionTokenHeader, sessionToken.Token)
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionTokenCookie,
+		Value:    sessionToken.Token,
+		Path:     "/api/sessions/" + c.Params("id"),
+		Expires:  sessionToken.ExpiresDateTime,
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
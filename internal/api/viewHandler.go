@@ -35,7 +35,7 @@ func formatPrice(price int) string {
 func (h *ViewHandler) HomePage(c *fiber.Ctx) error {
 	h.logger.Info("Home page accessed", nil)
 
-	productGroupsWithProducts, err := h.ProductService.GetProductGroupsWithProducts()
+	productGroupsWithProducts, err := h.ProductService.GetProductGroupsWithProducts(c.UserContext())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load product groups with products")
 	}
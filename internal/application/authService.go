@@ -0,0 +1,108 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+)
+
+type AuthService struct {
+	apiKeyRepository ports.APIKeyRepository
+	clock            ports.Clock
+	idGenerator      ports.IDGenerator
+	logger           ports.Logger
+}
+
+func NewAuthService(apiKeyRepository ports.APIKeyRepository, clock ports.Clock, idGenerator ports.IDGenerator, logger ports.Logger) *AuthService {
+	return &AuthService{
+		apiKeyRepository: apiKeyRepository,
+		clock:            clock,
+		idGenerator:      idGenerator,
+		logger:           logger,
+	}
+}
+
+// DTOCreatedAPIKey holds a new API key. Key is only ever returned here.
+type DTOCreatedAPIKey struct {
+	APIKey *domain.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+type DTOAPIKeyList struct {
+	APIKeys []*domain.APIKey `json:"api_keys"`
+}
+
+func (s *AuthService) CreateAPIKey(ctx context.Context, input domain.CreateAPIKeyInput) (*DTOCreatedAPIKey, error) {
+	apiKey, key, err := domain.CreateAPIKey(s.idGenerator.NewID(), input, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.apiKeyRepository.CreateAPIKey(ctx, apiKey)
+	if err != nil {
+		s.logger.Error("failed to create API key", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	s.logger.Info("created API key", map[string]interface{}{
+		"api_key_id": apiKey.ID,
+		"name":       apiKey.Name,
+		"roles":      apiKey.Roles,
+	})
+
+	return &DTOCreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context) (*DTOAPIKeyList, error) {
+	apiKeys, err := s.apiKeyRepository.ListAPIKeys(ctx)
+	if err != nil {
+		s.logger.Error("failed to list API keys", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOAPIKeyList{APIKeys: apiKeys}, nil
+}
+
+func (s *AuthService) DeleteAPIKey(ctx context.Context, id string) error {
+	uuidId, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.apiKeyRepository.DeleteAPIKey(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to delete API key", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate returns the principal an API key belongs to.
+func (s *AuthService) Authenticate(ctx context.Context, key string) (*domain.Principal, error) {
+	if !strings.HasPrefix(key, domain.APIKeyPrefix) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.apiKeyRepository.GetAPIKeyByHash(ctx, domain.HashAPIKey(key))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		s.logger.Error("failed to get API key", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return apiKey.Principal(), nil
+}
@@ -0,0 +1,276 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+)
+
+type CustomerService struct {
+	customerRepository ports.CustomerRepository
+	passwordHasher     ports.PasswordHasher
+	tokenSigner        ports.TokenSigner
+	tokenTTL           time.Duration
+	clock              ports.Clock
+	idGenerator        ports.IDGenerator
+	logger             ports.Logger
+	// dummyPasswordHash is compared against when no customer has the email,
+	// so that unknown emails take as long to reject as wrong passwords
+	dummyPasswordHash func() (string, error)
+}
+
+func NewCustomerService(
+	customerRepository ports.CustomerRepository,
+	passwordHasher ports.PasswordHasher,
+	tokenSigner ports.TokenSigner,
+	tokenTTL time.Duration,
+	clock ports.Clock,
+	idGenerator ports.IDGenerator,
+	logger ports.Logger) *CustomerService {
+	return &CustomerService{
+		customerRepository: customerRepository,
+		passwordHasher:     passwordHasher,
+		tokenSigner:        tokenSigner,
+		tokenTTL:           tokenTTL,
+		clock:              clock,
+		idGenerator:        idGenerator,
+		logger:             logger,
+		dummyPasswordHash: sync.OnceValues(func() (string, error) {
+			return passwordHasher.Hash("not the password of any customer")
+		}),
+	}
+}
+
+type DTOCustomerDetails struct {
+	Customer *domain.Customer `json:"customer"`
+}
+
+// DTOCustomerLogin is returned when a customer logs in. Token is sent as a
+// bearer token on the customer endpoints.
+type DTOCustomerLogin struct {
+	Customer        *domain.Customer `json:"customer"`
+	Token           string           `json:"token"`
+	ExpiresDateTime time.Time        `json:"expires_date_time"`
+	Order           *DTOOrderDetails `json:"order,omitempty"`
+}
+
+type DTOAddressDetails struct {
+	Address *domain.Address `json:"address"`
+}
+
+type DTOAddressList struct {
+	Addresses []*domain.Address `json:"addresses"`
+}
+
+const customerSubjectPrefix = "customer:"
+
+func (s *CustomerService) RegisterCustomer(ctx context.Context, input domain.RegisterCustomerInput) (*DTOCustomerDetails, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.customerRepository.GetCustomerByEmail(ctx, domain.NormalizeEmail(input.Email))
+	if err == nil {
+		return nil, domain.ErrEmailTaken
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		s.logger.Error("failed to get customer by email", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	passwordHash, err := s.passwordHasher.Hash(input.Password)
+	if err != nil {
+		s.logger.Error("failed to hash password", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	customer, err := domain.CreateCustomer(s.idGenerator.NewID(), input, passwordHash, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.customerRepository.CreateCustomer(ctx, customer)
+	if err != nil {
+		s.logger.Error("failed to create customer", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOCustomerDetails{Customer: customer}, nil
+}
+
+// Login checks the credentials of a customer and issues a customer token.
+func (s *CustomerService) Login(ctx context.Context, input domain.LoginCustomerInput) (*DTOCustomerLogin, error) {
+	customer, err := s.customerRepository.GetCustomerByEmail(ctx, domain.NormalizeEmail(input.Email))
+	if errors.Is(err, domain.ErrNotFound) {
+		hash, err := s.dummyPasswordHash()
+		if err != nil {
+			s.logger.Error("failed to hash dummy password", map[string]interface{}{
+				"error": err,
+			})
+			return nil, domain.ErrInvalidCredentials
+		}
+		s.passwordHasher.Compare(hash, input.Password)
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		s.logger.Error("failed to get customer by email", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	err = s.passwordHasher.Compare(customer.PasswordHash, input.Password)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidCredentials) {
+			s.logger.Error("failed to compare password", map[string]interface{}{
+				"error": err,
+			})
+		}
+		return nil, err
+	}
+
+	expires := s.clock.Now().Add(s.tokenTTL)
+	token, err := s.tokenSigner.Sign(customerSubjectPrefix+customer.ID.String(), expires)
+	if err != nil {
+		s.logger.Error("failed to sign customer token", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOCustomerLogin{Customer: customer, Token: token, ExpiresDateTime: expires}, nil
+}
+
+// Authenticate returns the ID of the customer a customer token was issued to.
+func (s *CustomerService) Authenticate(ctx context.Context, token string) (uuid.UUID, error) {
+	subject, err := s.tokenSigner.Verify(token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id, found := strings.CutPrefix(subject, customerSubjectPrefix)
+	if !found {
+		return uuid.Nil, domain.ErrInvalidToken
+	}
+	customerID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, domain.ErrInvalidToken
+	}
+
+	return customerID, nil
+}
+
+func (s *CustomerService) GetCustomer(ctx context.Context, customerID uuid.UUID) (*DTOCustomerDetails, error) {
+	customer, err := s.customerRepository.GetCustomerById(ctx, customerID)
+	if err != nil {
+		s.logger.Error("failed to get customer by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOCustomerDetails{Customer: customer}, nil
+}
+
+func (s *CustomerService) GetAddresses(ctx context.Context, customerID uuid.UUID) (*DTOAddressList, error) {
+	addresses, err := s.customerRepository.GetAddressesByCustomerId(ctx, customerID)
+	if err != nil {
+		s.logger.Error("failed to get addresses by customer ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOAddressList{Addresses: addresses}, nil
+}
+
+func (s *CustomerService) CreateAddress(ctx context.Context, customerID uuid.UUID, input domain.CreateAddressInput) (*DTOAddressDetails, error) {
+	address, err := domain.CreateAddress(s.idGenerator.NewID(), customerID, input, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.customerRepository.CreateAddress(ctx, address)
+	if err != nil {
+		s.logger.Error("failed to create address", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOAddressDetails{Address: address}, nil
+}
+
+func (s *CustomerService) UpdateAddress(ctx context.Context, customerID uuid.UUID, id string, input domain.UpdateAddressInput) (*DTOAddressDetails, error) {
+	address, err := s.getCustomerAddress(ctx, customerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = address.Update(input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.customerRepository.UpdateAddress(ctx, address)
+	if err != nil {
+		s.logger.Error("failed to update address", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOAddressDetails{Address: address}, nil
+}
+
+func (s *CustomerService) DeleteAddress(ctx context.Context, customerID uuid.UUID, id string) error {
+	address, err := s.getCustomerAddress(ctx, customerID, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.customerRepository.DeleteAddress(ctx, address.ID)
+	if err != nil {
+		s.logger.Error("failed to delete address", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	return nil
+}
+
+// getCustomerAddress gets an address of the customer. Addresses of other
+// customers are not found.
+func (s *CustomerService) getCustomerAddress(ctx context.Context, customerID uuid.UUID, id string) (*domain.Address, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := s.customerRepository.GetAddressById(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to get address by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+	if address.CustomerID != customerID {
+		return nil, domain.ErrAddressNotFound
+	}
+
+	return address, nil
+}
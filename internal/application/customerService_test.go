@@ -0,0 +1,64 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// countingPasswordHasher counts the passwords compared by the hasher it
+// wraps.
+type countingPasswordHasher struct {
+	*adapters.BcryptPasswordHasher
+	compared int
+}
+
+func (h *countingPasswordHasher) Compare(hash string, password string) error {
+	h.compared++
+	return h.BcryptPasswordHasher.Compare(hash, password)
+}
+
+func TestLoginComparesPasswordOfUnknownEmail(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        domain.LoginCustomerInput
+		wantErr      error
+		wantCompared int
+	}{
+		{name: "right password", input: domain.LoginCustomerInput{Email: "anna@example.com", Password: "correct horse"}, wantCompared: 1},
+		{name: "wrong password", input: domain.LoginCustomerInput{Email: "anna@example.com", Password: "wrong horse"}, wantErr: domain.ErrInvalidCredentials, wantCompared: 1},
+		{name: "unknown email", input: domain.LoginCustomerInput{Email: "bertil@example.com", Password: "correct horse"}, wantErr: domain.ErrInvalidCredentials, wantCompared: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			hasher := &countingPasswordHasher{BcryptPasswordHasher: adapters.NewBcryptPasswordHasher()}
+			customerService := application.NewCustomerService(
+				test.customerRepository,
+				hasher,
+				adapters.NewHMACTokenSigner(testCallbackSecret, test.clock),
+				time.Hour,
+				test.clock, test.idGenerator, test.logger)
+
+			_, err := customerService.RegisterCustomer(ctx, domain.RegisterCustomerInput{Email: "anna@example.com", Name: "Anna", Password: "correct horse"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = customerService.Login(ctx, tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if hasher.compared != tt.wantCompared {
+				t.Errorf("compared %d passwords, want %d", hasher.compared, tt.wantCompared)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package application
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,24 +12,69 @@ import (
 )
 
 type OrderService struct {
-	orderRepository ports.OrderRepository
-	logger          ports.Logger
+	unitOfWork          ports.UnitOfWork
+	orderRepository     ports.OrderRepository
+	productRepository   ports.ProductRepository
+	inventoryRepository ports.InventoryRepository
+	paymentRepository   ports.PaymentRepository
+	paymentProvider     ports.PaymentProvider
+	priceCalculator     *domain.PriceCalculator
+	reservationTTL      time.Duration
+	abandonedCartAge    time.Duration
+	clock               ports.Clock
+	idGenerator         ports.IDGenerator
+	logger              ports.Logger
 }
 
-func NewOrderService(orderRepository ports.OrderRepository, logger ports.Logger) *OrderService {
+func NewOrderService(
+	unitOfWork ports.UnitOfWork,
+	orderRepository ports.OrderRepository,
+	productRepository ports.ProductRepository,
+	inventoryRepository ports.InventoryRepository,
+	paymentRepository ports.PaymentRepository,
+	paymentProvider ports.PaymentProvider,
+	priceCalculator *domain.PriceCalculator,
+	reservationTTL time.Duration,
+	abandonedCartAge time.Duration,
+	clock ports.Clock,
+	idGenerator ports.IDGenerator,
+	logger ports.Logger) *OrderService {
 	return &OrderService{
-		orderRepository: orderRepository,
-		logger:          logger,
+		unitOfWork:          unitOfWork,
+		orderRepository:     orderRepository,
+		productRepository:   productRepository,
+		inventoryRepository: inventoryRepository,
+		paymentRepository:   paymentRepository,
+		paymentProvider:     paymentProvider,
+		priceCalculator:     priceCalculator,
+		reservationTTL:      reservationTTL,
+		abandonedCartAge:    abandonedCartAge,
+		clock:               clock,
+		idGenerator:         idGenerator,
+		logger:              logger,
 	}
 }
 
-func (s *OrderService) CreateOrder(input domain.CreateOrderInput) (*domain.Order, error) {
-	order, err := domain.CreateOrder(input)
+// inTransaction runs fn with a copy of the service whose repositories all take
+// part in the same transaction.
+func (s *OrderService) inTransaction(ctx context.Context, fn func(tx *OrderService) error) error {
+	return s.unitOfWork.Do(ctx, func(repositories ports.Repositories) error {
+		tx := *s
+		tx.orderRepository = repositories.Orders()
+		tx.productRepository = repositories.Products()
+		tx.inventoryRepository = repositories.Inventory()
+		tx.paymentRepository = repositories.Payments()
+		return fn(&tx)
+	})
+}
+
+func (s *OrderService) CreateOrder(ctx context.Context, input domain.CreateOrderInput) (*domain.Order, error) {
+	order, err := domain.CreateOrder(s.idGenerator.NewID(), input, s.clock.Now())
 	if err != nil {
 		return nil, err
 	}
 
-	order, err = s.orderRepository.CreateOrder(order)
+	order, err = s.orderRepository.CreateOrder(ctx, order)
 	if err != nil {
 		s.logger.Error("failed to create order", map[string]interface{}{
 			"error": err,
@@ -37,8 +85,8 @@ func (s *OrderService) CreateOrder(input domain.CreateOrderInput) (*domain.Order
 	return order, nil
 }
 
-func (s *OrderService) UpdateOrder(id string, input domain.UpdateOrderInput) (*domain.Order, error) {
-	uuidId, err := uuid.Parse(id)
+func (s *OrderService) UpdateOrder(ctx context.Context, id string, input domain.UpdateOrderInput) (*domain.Order, error) {
+	uuidId, err := parseID(id)
 	if err != nil {
 		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
@@ -46,7 +94,40 @@ func (s *OrderService) UpdateOrder(id string, input domain.UpdateOrderInput) (*d
 		return nil, err
 	}
 
-	order, err := s.orderRepository.GetOrderById(uuidId)
+	var order *domain.Order
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		var err error
+		order, err = tx.loadOrder(ctx, uuidId)
+		if err != nil {
+			return err
+		}
+
+		err = order.Update(input)
+		if err != nil {
+			return err
+		}
+
+		err = tx.orderRepository.UpdateOrder(ctx, order)
+		if err != nil {
+			tx.logger.Error("failed to update order", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// loadOrder reads the order. Changes to an order start by loading it in their
+// transaction, so that they check its status against what they overwrite.
+func (s *OrderService) loadOrder(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
+	order, err := s.orderRepository.GetOrderById(ctx, id)
 	if err != nil {
 		s.logger.Error("failed to get order by ID", map[string]interface{}{
 			"error": err,
@@ -54,24 +135,34 @@ func (s *OrderService) UpdateOrder(id string, input domain.UpdateOrderInput) (*d
 		return nil, err
 	}
 
-	err = order.Update(input)
+	return order, nil
+}
+
+type DTOOrderList struct {
+	Orders     []*domain.Order `json:"orders"`
+	Pagination *DTOPagination  `json:"pagination"`
+}
+
+// ListOrders finds the orders matching the query for order administration.
+func (s *OrderService) ListOrders(ctx context.Context, query domain.OrderQuery) (*DTOOrderList, error) {
+	query, err := query.Normalize()
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.orderRepository.UpdateOrder(order)
+	orders, total, err := s.orderRepository.ListOrders(ctx, query)
 	if err != nil {
-		s.logger.Error("failed to update order", map[string]interface{}{
+		s.logger.Error("failed to list orders", map[string]interface{}{
 			"error": err,
 		})
 		return nil, err
 	}
 
-	return order, nil
+	return &DTOOrderList{Orders: orders, Pagination: newDTOPagination(query.Page, total)}, nil
 }
 
-func (s *OrderService) GetOrderById(id string) (*domain.Order, error) {
-	uuidId, err := uuid.Parse(id)
+func (s *OrderService) GetOrderById(ctx context.Context, id string) (*domain.Order, error) {
+	uuidId, err := parseID(id)
 	if err != nil {
 		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
@@ -79,7 +170,7 @@ func (s *OrderService) GetOrderById(id string) (*domain.Order, error) {
 		return nil, err
 	}
 
-	order, err := s.orderRepository.GetOrderById(uuidId)
+	order, err := s.orderRepository.GetOrderById(ctx, uuidId)
 	if err != nil {
 		s.logger.Error("failed to get order by ID", map[string]interface{}{
 			"error": err,
@@ -90,8 +181,8 @@ func (s *OrderService) GetOrderById(id string) (*domain.Order, error) {
 	return order, nil
 }
 
-func (s *OrderService) DeleteOrder(id string) error {
-	uuidId, err := uuid.Parse(id)
+func (s *OrderService) DeleteOrder(ctx context.Context, id string) error {
+	uuidId, err := parseID(id)
 	if err != nil {
 		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
@@ -99,7 +190,24 @@ func (s *OrderService) DeleteOrder(id string) error {
 		return err
 	}
 
-	err = s.orderRepository.DeleteOrder(uuidId)
+	return s.inTransaction(ctx, func(tx *OrderService) error {
+		order, err := tx.loadOrder(ctx, uuidId)
+		if err != nil {
+			return err
+		}
+		// Checked out orders hold stock, payments and an order number
+		if !order.IsOpen() {
+			return domain.ErrOrderNotDeletable
+		}
+
+		return tx.deleteOrder(ctx, uuidId)
+	})
+}
+
+// deleteOrder deletes the order. The repository deletes its stock
+// reservations with it.
+func (s *OrderService) deleteOrder(ctx context.Context, id uuid.UUID) error {
+	err := s.orderRepository.DeleteOrder(ctx, id)
 	if err != nil {
 		s.logger.Error("failed to delete order", map[string]interface{}{
 			"error": err,
@@ -110,143 +218,1583 @@ func (s *OrderService) DeleteOrder(id string) error {
 	return nil
 }
 
-func (s *OrderService) GetOrderDetailsBySessionId(sessionId string) (*DTOOrderDetails, error) {
-	order, err := s.orderRepository.GetOrderBySessionId(sessionId)
+func (s *OrderService) transitionOrder(ctx context.Context, id string, status domain.OrderStatus, actor string) (*domain.Order, error) {
+	uuidId, err := parseID(id)
 	if err != nil {
-		s.logger.Error("failed to get order by session ID", map[string]interface{}{
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
 		})
 		return nil, err
 	}
 
-	orderLines, err := s.orderRepository.GetOrderLinesByOrderId(order.ID)
+	var order *domain.Order
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		var err error
+		order, err = tx.loadOrder(ctx, uuidId)
+		if err != nil {
+			return err
+		}
+
+		return tx.applyTransition(ctx, order, status, actor)
+	})
 	if err != nil {
-		s.logger.Error("failed to get order lines by order ID", map[string]interface{}{
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// applyTransition moves the order to status, saves it and records the
+// transition. Callers run it in a transaction.
+func (s *OrderService) applyTransition(ctx context.Context, order *domain.Order, status domain.OrderStatus, actor string) error {
+	transition, err := order.TransitionTo(status, actor, s.idGenerator.NewID(), s.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	err = s.orderRepository.UpdateOrder(ctx, order)
+	if err != nil {
+		s.logger.Error("failed to update order", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	err = s.orderRepository.CreateOrderStatusTransition(ctx, transition)
+	if err != nil {
+		s.logger.Error("failed to record order status transition", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	s.logger.Info("order status changed", map[string]interface{}{
+		"order_id": order.ID,
+		"from":     transition.FromStatus,
+		"to":       transition.ToStatus,
+		"actor":    actor,
+	})
+
+	return nil
+}
+
+func (s *OrderService) CheckoutOrder(ctx context.Context, id string, input domain.CheckoutInput, actor string) (*DTOOrderDetails, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
 		})
 		return nil, err
 	}
 
-	dtoOrderLines := make([]DTOOrderLine, len(orderLines))
-	for i, orderLine := range orderLines {
-		contentLines, err := s.orderRepository.GetOrderLineContentLinesByOrderLineId(orderLine.ID)
+	return s.checkoutOrder(ctx, uuidId, input, actor)
+}
+
+func (s *OrderService) CheckoutSessionOrder(ctx context.Context, sessionId string, input domain.CheckoutInput) (*DTOOrderDetails, error) {
+	order, err := s.getOpenSessionOrder(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.checkoutOrder(ctx, order.ID, input, domain.ActorCustomer)
+}
+
+// checkoutOrder validates the customer details, freezes the lines with the
+// current catalog data, assigns an order number and moves the order to the
+// checkout status.
+func (s *OrderService) checkoutOrder(ctx context.Context, id uuid.UUID, input domain.CheckoutInput, actor string) (*DTOOrderDetails, error) {
+	var order *domain.Order
+	err := s.inTransaction(ctx, func(tx *OrderService) error {
+		var err error
+		order, err = tx.loadOrder(ctx, id)
 		if err != nil {
-			s.logger.Error("failed to get order line content lines by order line ID", map[string]interface{}{
-				"error": err,
-			})
-			return nil, err
+			return err
 		}
 
-		dtoContentLines := make([]DTOOrderLineContentLine, len(contentLines))
-		for j, contentLine := range contentLines {
-			dtoContentLines[j] = DTOOrderLineContentLine{
-				ID:          contentLine.ID,
-				OrderLineID: contentLine.OrderLineID,
-				ProductID:   contentLine.ProductID,
-				Product:     domain.Product{}, // Assuming you have a way to get the product details
-				Quantity:    contentLine.Quantity,
-			}
+		if !order.IsOpen() {
+			return domain.ErrOrderNotOpen
 		}
 
-		dtoOrderLines[i] = DTOOrderLine{
-			ID:           orderLine.ID,
-			OrderID:      orderLine.OrderID,
-			ProductID:    orderLine.ProductID,
-			Product:      domain.Product{}, // Assuming you have a way to get the product details
-			ContentLines: dtoContentLines,
+		err = order.SetCustomerDetails(input)
+		if err != nil {
+			return err
 		}
-	}
 
-	dtoOrder := DTOOrder{
-		ID:              order.ID,
-		SessionID:       order.SessionId,
-		Email:           order.Email,
-		Name:            order.Name,
-		Address:         order.Address,
-		ZipCode:         order.ZipCode,
-		City:            order.City,
-		CompanyName:     order.CompanyName,
-		Status:          order.Status,
-		CreatedDateTime: order.CreatedDateTime.String(),
-		OrderLines:      dtoOrderLines,
+		return tx.freezeOrder(ctx, order, actor)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &DTOOrderDetails{Order: dtoOrder}, nil
-}
+	s.logger.Info("order checked out", map[string]interface{}{
+		"order_id":     order.ID,
+		"order_number": order.OrderNumber,
+		"actor":        actor,
+	})
 
-type DTOOrderDetails struct {
-	Order DTOOrder `json:"order"`
+	return s.buildOrderDetails(ctx, order)
 }
 
-type DTOOrder struct {
-	ID              uuid.UUID      `json:"id"`
-	SessionID       string         `json:"session_id"`
-	Email           string         `json:"email"`
-	Name            string         `json:"name"`
-	Address         string         `json:"address"`
-	ZipCode         string         `json:"zip_code"`
-	City            string         `json:"city"`
-	CompanyName     string         `json:"company_name"`
-	Status          string         `json:"status"`
-	CreatedDateTime string         `json:"created_date_time"`
-	OrderLines      []DTOOrderLine `json:"order_lines"`
-}
+// freezeOrder snapshots the lines, takes the goods from stock and moves the
+// order to checkout. Callers run it in a transaction.
+func (s *OrderService) freezeOrder(ctx context.Context, order *domain.Order, actor string) error {
+	contents, err := s.snapshotOrderLines(ctx, order)
+	if err != nil {
+		return err
+	}
 
-type DTOOrderLine struct {
-	ID           uuid.UUID                 `json:"id"`
-	OrderID      uuid.UUID                 `json:"order_id"`
-	ProductID    uuid.UUID                 `json:"product_id"`
-	Product      domain.Product            `json:"product"`
-	ContentLines []DTOOrderLineContentLine `json:"content_lines"`
-}
+	now := s.clock.Now()
+	err = s.inventoryRepository.ConsumeStock(ctx, order.ID, domain.StockRequirements(contents.orderLines, contents.contentLinesByOrderLineID), now)
+	if err != nil {
+		s.logger.Warn("failed to consume stock for order", map[string]interface{}{
+			"error":    err,
+			"order_id": order.ID,
+		})
+		return err
+	}
 
-type DTOOrderLineContentLine struct {
-	ID          uuid.UUID      `json:"id"`
-	OrderLineID uuid.UUID      `json:"order_line_id"`
-	ProductID   uuid.UUID      `json:"product_id"`
-	Product     domain.Product `json:"product"`
-	Quantity    int            `json:"quantity"`
-}
+	sequence, err := s.orderRepository.NextOrderNumber(ctx, order.ID)
+	if err != nil {
+		s.logger.Error("failed to get next order number", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
 
-func (s *OrderService) CreateSessionOrder(sessionId uuid.UUID) (*domain.Order, error) {
-	createOrderInput := domain.CreateOrderInput{
-		SessionId: sessionId.String(),
+	prices := s.priceCalculator.Calculate(contents.orderLines)
+	transition, err := order.Checkout(domain.FormatOrderNumber(sequence, now), prices, actor, s.idGenerator.NewID(), now)
+	if err != nil {
+		return err
 	}
 
-	order, err := s.CreateOrder(createOrderInput)
+	err = s.orderRepository.UpdateOrder(ctx, order)
 	if err != nil {
-		s.logger.Error("failed to create session order", map[string]interface{}{
+		s.logger.Error("failed to update order", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	err = s.orderRepository.CreateOrderStatusTransition(ctx, transition)
+	if err != nil {
+		s.logger.Error("failed to record order status transition", map[string]interface{}{
 			"error": err,
 		})
+		return err
+	}
+
+	return nil
+}
+
+// snapshotOrderLines copies the current catalog name and price onto every
+// line and content line of the order.
+func (s *OrderService) snapshotOrderLines(ctx context.Context, order *domain.Order) (*orderContents, error) {
+	contents, err := s.loadOrderContents(ctx, order)
+	if err != nil {
 		return nil, err
 	}
 
-	return order, nil
+	validationErr := &domain.ValidationError{}
+	if len(contents.orderLines) == 0 {
+		validationErr.Add("order_lines", "order has no lines")
+	}
+	for i, orderLine := range contents.orderLines {
+		if _, ok := contents.productsByID[orderLine.ProductID]; !ok {
+			validationErr.Addf(fmt.Sprintf("order_lines[%d].product_id", i), "product %s no longer exists", orderLine.ProductID)
+		}
+		for j, contentLine := range contents.contentLinesByOrderLineID[orderLine.ID] {
+			if _, ok := contents.productsByID[contentLine.ProductID]; !ok {
+				validationErr.Addf(fmt.Sprintf("order_lines[%d].content_lines[%d].product_id", i, j), "product %s no longer exists", contentLine.ProductID)
+			}
+		}
+	}
+	if err := validationErr.ErrOrNil(); err != nil {
+		return nil, err
+	}
+
+	for _, orderLine := range contents.orderLines {
+		product := contents.productsByID[orderLine.ProductID]
+		orderLine.ProductName = product.Name
+		orderLine.Price = s.priceCalculator.UnitPrice(&product)
+
+		err = s.orderRepository.UpdateOrderLine(ctx, orderLine)
+		if err != nil {
+			s.logger.Error("failed to update order line", map[string]interface{}{
+				"error": err,
+			})
+			return nil, err
+		}
+
+		for _, contentLine := range contents.contentLinesByOrderLineID[orderLine.ID] {
+			contentLine.ProductName = contents.productsByID[contentLine.ProductID].Name
+
+			err = s.orderRepository.UpdateOrderLineContentLine(ctx, contentLine)
+			if err != nil {
+				s.logger.Error("failed to update order line content line", map[string]interface{}{
+					"error": err,
+				})
+				return nil, err
+			}
+		}
+	}
+
+	return contents, nil
 }
 
-func (s *OrderService) RemoveOldCreatedOrders() error {
-	orders, err := s.orderRepository.GetOrderByStatus("created")
+// CancelOrder cancels the order and releases its reservations or puts the
+// stock taken at checkout back.
+func (s *OrderService) CancelOrder(ctx context.Context, id string, actor string) (*domain.Order, error) {
+	uuidId, err := parseID(id)
 	if err != nil {
-		s.logger.Error("failed to get orders by status", map[string]interface{}{
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
 		})
-
-		return err
+		return nil, err
 	}
 
-	for _, order := range orders {
-		if order.CreatedDateTime.Add(10 * time.Minute).Before(time.Now()) {
-			err = s.orderRepository.DeleteOrder(order.ID)
+	var order *domain.Order
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		var err error
+		order, err = tx.loadOrder(ctx, uuidId)
+		if err != nil {
+			return err
+		}
+
+		holdsStock := order.Status == domain.OrderStatusCheckout
+		holdsReservations := order.Status == domain.OrderStatusCreated
+
+		err = tx.applyTransition(ctx, order, domain.OrderStatusCancelled, actor)
+		if err != nil {
+			return err
+		}
+
+		if holdsReservations {
+			err = tx.inventoryRepository.DeleteReservationsByOrderId(ctx, order.ID)
 			if err != nil {
-				s.logger.Error("failed to delete order", map[string]interface{}{
-					"error": err,
+				tx.logger.Error("failed to release stock reservations", map[string]interface{}{
+					"error":    err,
+					"order_id": order.ID,
 				})
 				return err
 			}
-			s.logger.Info("deleted old created order", map[string]interface{}{
-				"order_id": order.ID,
-			})
 		}
+
+		if holdsStock {
+			return tx.releaseOrderStock(ctx, order)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// releaseOrderStock puts everything the order consumed at checkout back into stock.
+func (s *OrderService) releaseOrderStock(ctx context.Context, order *domain.Order) error {
+	contents, err := s.loadOrderContents(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	err = s.inventoryRepository.ReleaseStock(ctx, domain.StockRequirements(contents.orderLines, contents.contentLinesByOrderLineID), s.clock.Now())
+	if err != nil {
+		s.logger.Error("failed to release stock", map[string]interface{}{
+			"error":    err,
+			"order_id": order.ID,
+		})
+		return err
 	}
+
 	return nil
 }
+
+func (s *OrderService) StartOrderProduction(ctx context.Context, id string, actor string) (*domain.Order, error) {
+	return s.transitionOrder(ctx, id, domain.OrderStatusInProduction, actor)
+}
+
+func (s *OrderService) ShipOrder(ctx context.Context, id string, actor string) (*domain.Order, error) {
+	return s.transitionOrder(ctx, id, domain.OrderStatusShipped, actor)
+}
+
+func (s *OrderService) DeliverOrder(ctx context.Context, id string, actor string) (*domain.Order, error) {
+	return s.transitionOrder(ctx, id, domain.OrderStatusDelivered, actor)
+}
+
+// RefundOrder moves the order to refunded and refunds its captured payment
+// with the payment provider. The payment is recorded as refunding together
+// with the order, so that a second refund of the same order fails before
+// reaching the provider, and as refunded once the provider has refunded it.
+// Refunding the order again retries a refund the provider failed.
+func (s *OrderService) RefundOrder(ctx context.Context, id string, actor string) (*domain.Order, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	var order *domain.Order
+	var refund *domain.Payment
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		var err error
+		order, err = tx.loadOrder(ctx, uuidId)
+		if err != nil {
+			return err
+		}
+
+		payments, err := tx.paymentRepository.GetPaymentsByOrderId(ctx, order.ID)
+		if err != nil {
+			tx.logger.Error("failed to get payments by order ID", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		if order.Status == domain.OrderStatusRefunded {
+			for _, payment := range payments {
+				if payment.Status == domain.PaymentStatusRefunding {
+					refund = payment
+				}
+			}
+			if refund != nil {
+				return nil
+			}
+		}
+
+		// Goods of orders refunded before production are still in stock
+		holdsStock := order.Status == domain.OrderStatusPaid
+
+		err = tx.applyTransition(ctx, order, domain.OrderStatusRefunded, actor)
+		if err != nil {
+			return err
+		}
+
+		for _, payment := range payments {
+			if payment.Status == domain.PaymentStatusCaptured {
+				refund = payment
+			}
+		}
+		if refund == nil {
+			return domain.ErrNoCapturedPayment
+		}
+
+		err = refund.MarkRefunding(tx.clock.Now())
+		if err != nil {
+			return err
+		}
+
+		err = tx.updatePayment(ctx, refund)
+		if err != nil {
+			return err
+		}
+
+		if holdsStock {
+			err = tx.releaseOrderStock(ctx, order)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.settlePayment(ctx, refund)
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+type DTOPaymentDetails struct {
+	Payment     domain.Payment `json:"payment"`
+	RedirectURL string         `json:"redirect_url"`
+}
+
+type DTOPaymentList struct {
+	Payments []*domain.Payment `json:"payments"`
+}
+
+// StartSessionPayment creates a payment intent with the payment provider for
+// the total of a checked out session order. A payment already pending for the
+// order is returned instead, so that the order has one open payment at most.
+func (s *OrderService) StartSessionPayment(ctx context.Context, sessionId string) (*DTOPaymentDetails, error) {
+	order, err := s.orderRepository.GetOrderBySessionId(ctx, sessionId)
+	if err != nil {
+		s.logger.Error("failed to get order by session ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	if order.Status != domain.OrderStatusCheckout {
+		return nil, domain.ErrOrderNotAwaitingPayment
+	}
+
+	payment, err := s.pendingPayment(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payment != nil {
+		return &DTOPaymentDetails{Payment: *payment, RedirectURL: payment.RedirectURL}, nil
+	}
+
+	orderLines, err := s.orderRepository.GetOrderLinesByOrderId(ctx, order.ID)
+	if err != nil {
+		s.logger.Error("failed to get order lines by order ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	amount := s.orderPrices(order, orderLines).Total
+
+	// The intent is created outside the transaction so that the database is
+	// not held while waiting for the provider
+	intent, err := s.paymentProvider.CreatePaymentIntent(ctx, order, amount, domain.CurrencySEK)
+	if err != nil {
+		s.logger.Error("failed to create payment intent", map[string]interface{}{
+			"error":    err,
+			"order_id": order.ID,
+		})
+		return nil, err
+	}
+
+	newPayment, err := domain.CreatePayment(s.idGenerator.NewID(), order.ID, s.paymentProvider.Name(), intent, amount, domain.CurrencySEK, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		order, err := tx.loadOrder(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		if order.Status != domain.OrderStatusCheckout {
+			return domain.ErrOrderNotAwaitingPayment
+		}
+
+		// A payment started concurrently wins, the new intent is never completed
+		payment, err = tx.pendingPayment(ctx, order.ID)
+		if err != nil || payment != nil {
+			return err
+		}
+
+		payment = newPayment
+		err = tx.paymentRepository.CreatePayment(ctx, payment)
+		if err != nil {
+			tx.logger.Error("failed to create payment", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DTOPaymentDetails{Payment: *payment, RedirectURL: payment.RedirectURL}, nil
+}
+
+// pendingPayment returns the pending payment of the order, or nil if it has
+// none.
+func (s *OrderService) pendingPayment(ctx context.Context, orderId uuid.UUID) (*domain.Payment, error) {
+	payments, err := s.paymentRepository.GetPaymentsByOrderId(ctx, orderId)
+	if err != nil {
+		s.logger.Error("failed to get payments by order ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	for _, payment := range payments {
+		if payment.IsPending() {
+			return payment, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// HandlePaymentCallback applies a callback from the payment provider. An
+// authorized payment moves the order to paid and is recorded as capturing in
+// one transaction, and is only captured with the provider once that has been
+// committed. An authorized payment of an order that no longer awaits payment,
+// e.g. one already paid through another payment, is voided instead. Repeated
+// callbacks retry the provider call of a payment still waiting for it and are
+// otherwise ignored.
+func (s *OrderService) HandlePaymentCallback(ctx context.Context, provider string, body []byte, headers map[string]string) error {
+	if provider != s.paymentProvider.Name() {
+		return domain.Errorf(domain.ErrNotFound, "unknown payment provider %q", provider)
+	}
+
+	callback, err := s.paymentProvider.HandleCallback(ctx, body, headers)
+	if err != nil {
+		s.logger.Warn("rejected payment callback", map[string]interface{}{
+			"error":    err,
+			"provider": provider,
+		})
+		return err
+	}
+
+	var settling *domain.Payment
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		payment, err := tx.paymentRepository.GetPaymentByProviderReference(ctx, provider, callback.Reference)
+		if err != nil {
+			tx.logger.Error("failed to get payment by provider reference", map[string]interface{}{
+				"error":     err,
+				"reference": callback.Reference,
+			})
+			return err
+		}
+
+		if payment.IsSettling() {
+			settling = payment
+			return nil
+		}
+
+		if !payment.IsPending() {
+			tx.logger.Info("ignoring callback for settled payment", map[string]interface{}{
+				"payment_id": payment.ID,
+				"status":     payment.Status,
+			})
+			return nil
+		}
+
+		if !callback.Authorized {
+			err = payment.MarkFailed(tx.clock.Now())
+			if err != nil {
+				return err
+			}
+			return tx.updatePayment(ctx, payment)
+		}
+
+		order, err := tx.loadOrder(ctx, payment.OrderID)
+		if err != nil {
+			return err
+		}
+
+		if order.Status != domain.OrderStatusCheckout {
+			tx.logger.Warn("voiding payment of order not awaiting payment", map[string]interface{}{
+				"payment_id":   payment.ID,
+				"order_status": order.Status,
+			})
+			err = payment.MarkVoiding(tx.clock.Now())
+		} else {
+			err = tx.applyTransition(ctx, order, domain.OrderStatusPaid, "payment:"+provider)
+			if err != nil {
+				return err
+			}
+			err = payment.MarkCapturing(tx.clock.Now())
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.updatePayment(ctx, payment)
+		if err != nil {
+			return err
+		}
+
+		settling = payment
+		return nil
+	})
+	if err != nil || settling == nil {
+		return err
+	}
+
+	return s.settlePayment(ctx, settling)
+}
+
+// settlePayment asks the payment provider to capture, void or refund a
+// payment waiting for it and records that it has. The idempotency key is made
+// of the payment and the action, so that a retry after a failure, or a
+// concurrent callback, has the provider act once.
+func (s *OrderService) settlePayment(ctx context.Context, settling *domain.Payment) error {
+	idempotencyKey := settling.ID.String() + ":" + string(settling.Status)
+
+	var err error
+	switch settling.Status {
+	case domain.PaymentStatusCapturing:
+		err = s.paymentProvider.Capture(ctx, settling.ProviderReference, settling.Amount, idempotencyKey)
+	case domain.PaymentStatusVoiding:
+		err = s.paymentProvider.Void(ctx, settling.ProviderReference, idempotencyKey)
+	case domain.PaymentStatusRefunding:
+		err = s.paymentProvider.Refund(ctx, settling.ProviderReference, settling.Amount, idempotencyKey)
+	default:
+		return domain.ErrPaymentNotSettling
+	}
+	if err != nil {
+		s.logger.Error("failed to settle payment with payment provider", map[string]interface{}{
+			"error":      err,
+			"payment_id": settling.ID,
+			"status":     settling.Status,
+		})
+		return err
+	}
+
+	return s.inTransaction(ctx, func(tx *OrderService) error {
+		payment, err := tx.paymentRepository.GetPaymentByProviderReference(ctx, settling.Provider, settling.ProviderReference)
+		if err != nil {
+			tx.logger.Error("failed to get payment by provider reference", map[string]interface{}{
+				"error":     err,
+				"reference": settling.ProviderReference,
+			})
+			return err
+		}
+
+		// A concurrent callback has already recorded it
+		if payment.Status != settling.Status {
+			return nil
+		}
+
+		err = payment.MarkSettled(tx.clock.Now())
+		if err != nil {
+			return err
+		}
+
+		return tx.updatePayment(ctx, payment)
+	})
+}
+
+func (s *OrderService) updatePayment(ctx context.Context, payment *domain.Payment) error {
+	err := s.paymentRepository.UpdatePayment(ctx, payment)
+	if err != nil {
+		s.logger.Error("failed to update payment", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	return nil
+}
+
+func (s *OrderService) GetOrderPayments(ctx context.Context, id string) (*DTOPaymentList, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	payments, err := s.paymentRepository.GetPaymentsByOrderId(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to get payments by order ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOPaymentList{Payments: payments}, nil
+}
+
+func (s *OrderService) GetOrderStatusTransitions(ctx context.Context, id string) ([]*domain.OrderStatusTransition, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	transitions, err := s.orderRepository.GetOrderStatusTransitionsByOrderId(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to get order status transitions", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+func (s *OrderService) GetOrderDetailsBySessionId(ctx context.Context, sessionId string) (*DTOOrderDetails, error) {
+	order, err := s.orderRepository.GetOrderBySessionId(ctx, sessionId)
+	if err != nil {
+		s.logger.Error("failed to get order by session ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return s.buildOrderDetails(ctx, order)
+}
+
+// orderContents holds the lines of an order together with their content
+// lines and the catalog products they reference.
+type orderContents struct {
+	orderLines                []*domain.OrderLine
+	contentLinesByOrderLineID map[uuid.UUID][]*domain.OrderLineContentLine
+	productsByID              map[uuid.UUID]domain.Product
+}
+
+// loadOrderContents loads the lines, content lines and products of an order
+// with one query each.
+func (s *OrderService) loadOrderContents(ctx context.Context, order *domain.Order) (*orderContents, error) {
+	orderLines, err := s.orderRepository.GetOrderLinesByOrderId(ctx, order.ID)
+	if err != nil {
+		s.logger.Error("failed to get order lines by order ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	orderLineIDs := make([]uuid.UUID, len(orderLines))
+	productIDs := make([]uuid.UUID, 0, len(orderLines))
+	for i, orderLine := range orderLines {
+		orderLineIDs[i] = orderLine.ID
+		productIDs = append(productIDs, orderLine.ProductID)
+	}
+
+	contentLines, err := s.orderRepository.GetOrderLineContentLinesByOrderLineIds(ctx, orderLineIDs)
+	if err != nil {
+		s.logger.Error("failed to get order line content lines by order line IDs", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	contentLinesByOrderLineID := make(map[uuid.UUID][]*domain.OrderLineContentLine, len(orderLines))
+	for _, contentLine := range contentLines {
+		contentLinesByOrderLineID[contentLine.OrderLineID] = append(contentLinesByOrderLineID[contentLine.OrderLineID], contentLine)
+		productIDs = append(productIDs, contentLine.ProductID)
+	}
+
+	products, err := s.productRepository.GetProductsByIDs(ctx, productIDs)
+	if err != nil {
+		s.logger.Error("failed to get products by IDs", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	productsByID := make(map[uuid.UUID]domain.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+
+	return &orderContents{
+		orderLines:                orderLines,
+		contentLinesByOrderLineID: contentLinesByOrderLineID,
+		productsByID:              productsByID,
+	}, nil
+}
+
+// orderPrices returns the prices of the order. Open carts are priced with the
+// current rules, orders past checkout keep the amounts fixed at checkout.
+func (s *OrderService) orderPrices(order *domain.Order, orderLines []*domain.OrderLine) domain.PriceBreakdown {
+	prices := s.priceCalculator.Calculate(orderLines)
+	if order.IsOpen() {
+		return prices
+	}
+	// Orders checked out before the amounts were stored are still priced
+	// when read
+	if order.Subtotal == 0 && prices.Subtotal != 0 {
+		return prices
+	}
+
+	prices.Subtotal = order.Subtotal
+	prices.Discount = order.Discount
+	prices.VAT = order.VAT
+	prices.Total = order.Total
+	return prices
+}
+
+// buildOrderDetails assembles the order, its lines and their products into a
+// DTOOrderDetails. Open carts show the current catalog and prices. Orders
+// past checkout show the names, prices and totals frozen at checkout.
+func (s *OrderService) buildOrderDetails(ctx context.Context, order *domain.Order) (*DTOOrderDetails, error) {
+	contents, err := s.loadOrderContents(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	orderLines := contents.orderLines
+
+	// Open carts are priced as checkout will price them. The stored line
+	// prices are only brought up to date when the cart changes.
+	if order.IsOpen() {
+		for _, orderLine := range orderLines {
+			if product, ok := contents.productsByID[orderLine.ProductID]; ok {
+				orderLine.Price = s.priceCalculator.UnitPrice(&product)
+			}
+		}
+	}
+
+	product := func(productID uuid.UUID, productName string, price int) domain.Product {
+		snapshot := domain.Product{ID: productID, Name: productName, Price: price}
+		if !order.IsOpen() {
+			return snapshot
+		}
+
+		product, ok := contents.productsByID[productID]
+		if !ok {
+			s.logger.Warn("product referenced by order no longer exists", map[string]interface{}{
+				"order_id":   order.ID,
+				"product_id": productID,
+			})
+			return snapshot
+		}
+		return product
+	}
+
+	prices := s.orderPrices(order, orderLines)
+
+	dtoOrderLines := make([]DTOOrderLine, len(orderLines))
+	for i, orderLine := range orderLines {
+		lineContentLines := contents.contentLinesByOrderLineID[orderLine.ID]
+
+		dtoContentLines := make([]DTOOrderLineContentLine, len(lineContentLines))
+		for j, contentLine := range lineContentLines {
+			dtoContentLines[j] = DTOOrderLineContentLine{
+				ID:          contentLine.ID,
+				OrderLineID: contentLine.OrderLineID,
+				ProductID:   contentLine.ProductID,
+				Product:     product(contentLine.ProductID, contentLine.ProductName, 0),
+				Quantity:    contentLine.Quantity,
+			}
+		}
+
+		dtoOrderLines[i] = DTOOrderLine{
+			ID:           orderLine.ID,
+			OrderID:      orderLine.OrderID,
+			ProductID:    orderLine.ProductID,
+			Product:      product(orderLine.ProductID, orderLine.ProductName, orderLine.Price),
+			Quantity:     orderLine.Quantity,
+			UnitPrice:    prices.Lines[i].UnitPrice,
+			LineTotal:    prices.Lines[i].Total,
+			ContentLines: dtoContentLines,
+		}
+	}
+
+	dtoOrder := DTOOrder{
+		ID:              order.ID,
+		SessionID:       order.SessionId,
+		CustomerID:      order.CustomerID,
+		Email:           order.Email,
+		Name:            order.Name,
+		Address:         order.Address,
+		ZipCode:         order.ZipCode,
+		City:            order.City,
+		CompanyName:     order.CompanyName,
+		OrderNumber:     order.OrderNumber,
+		Status:          order.Status,
+		CreatedDateTime: order.CreatedDateTime.String(),
+		OrderLines:      dtoOrderLines,
+		Subtotal:        prices.Subtotal,
+		Discount:        prices.Discount,
+		VAT:             prices.VAT,
+		Total:           prices.Total,
+	}
+
+	return &DTOOrderDetails{Order: dtoOrder}, nil
+}
+
+type DTOOrderDetails struct {
+	Order DTOOrder `json:"order"`
+}
+
+type DTOOrder struct {
+	ID              uuid.UUID          `json:"id"`
+	SessionID       string             `json:"session_id"`
+	CustomerID      *uuid.UUID         `json:"customer_id"`
+	Email           string             `json:"email"`
+	Name            string             `json:"name"`
+	Address         string             `json:"address"`
+	ZipCode         string             `json:"zip_code"`
+	City            string             `json:"city"`
+	CompanyName     string             `json:"company_name"`
+	OrderNumber     string             `json:"order_number"`
+	Status          domain.OrderStatus `json:"status"`
+	CreatedDateTime string             `json:"created_date_time"`
+	OrderLines      []DTOOrderLine     `json:"order_lines"`
+	Subtotal        int                `json:"subtotal"`
+	Discount        int                `json:"discount"`
+	VAT             int                `json:"vat"`
+	Total           int                `json:"total"`
+}
+
+type DTOOrderLine struct {
+	ID           uuid.UUID                 `json:"id"`
+	OrderID      uuid.UUID                 `json:"order_id"`
+	ProductID    uuid.UUID                 `json:"product_id"`
+	Product      domain.Product            `json:"product"`
+	Quantity     int                       `json:"quantity"`
+	UnitPrice    int                       `json:"unit_price"`
+	LineTotal    int                       `json:"line_total"`
+	ContentLines []DTOOrderLineContentLine `json:"content_lines"`
+}
+
+type DTOOrderLineContentLine struct {
+	ID          uuid.UUID      `json:"id"`
+	OrderLineID uuid.UUID      `json:"order_line_id"`
+	ProductID   uuid.UUID      `json:"product_id"`
+	Product     domain.Product `json:"product"`
+	Quantity    int            `json:"quantity"`
+}
+
+// CreateSessionOrder creates the cart of a new session. A session only ever
+// has one order, so that whoever created it stays its only owner.
+func (s *OrderService) CreateSessionOrder(ctx context.Context, sessionId uuid.UUID) (*domain.Order, error) {
+	_, err := s.orderRepository.GetOrderBySessionId(ctx, sessionId.String())
+	if err == nil {
+		return nil, domain.ErrSessionHasOrder
+	}
+	if !errors.Is(err, domain.ErrNotFound) {
+		s.logger.Error("failed to get order by session ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	createOrderInput := domain.CreateOrderInput{
+		SessionId: sessionId.String(),
+	}
+
+	order, err := s.CreateOrder(ctx, createOrderInput)
+	if err != nil {
+		s.logger.Error("failed to create session order", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (s *OrderService) getOpenSessionOrder(ctx context.Context, sessionId string) (*domain.Order, error) {
+	order, err := s.orderRepository.GetOrderBySessionId(ctx, sessionId)
+	if err != nil {
+		s.logger.Error("failed to get order by session ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	if !order.IsOpen() {
+		return nil, domain.ErrOrderNotOpen
+	}
+
+	return order, nil
+}
+
+func (s *OrderService) getSessionOrderLine(ctx context.Context, order *domain.Order, orderLineId string) (*domain.OrderLine, error) {
+	uuidId, err := parseID(orderLineId)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	orderLine, err := s.orderRepository.GetOrderLineById(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to get order line by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	if orderLine.OrderID != order.ID {
+		return nil, domain.ErrOrderLineNotInOrder
+	}
+
+	return orderLine, nil
+}
+
+func (s *OrderService) validateOrderLineComposition(ctx context.Context, input domain.AddOrderLineInput) (*domain.Product, error) {
+	product, err := s.productRepository.GetProduct(ctx, input.ProductID)
+	if err != nil {
+		s.logger.Error("failed to get product by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	productGroup, err := s.productRepository.GetProductGroup(ctx, product.ProductGroupID)
+	if err != nil {
+		s.logger.Error("failed to get product group by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	contentProductIDs := make([]uuid.UUID, len(input.ContentLines))
+	for i, contentLine := range input.ContentLines {
+		contentProductIDs[i] = contentLine.ProductID
+	}
+
+	contentProducts, err := s.productRepository.GetProductsByIDs(ctx, contentProductIDs)
+	if err != nil {
+		s.logger.Error("failed to get content products by IDs", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	contentProductsByID := make(map[uuid.UUID]domain.Product, len(contentProducts))
+	for _, contentProduct := range contentProducts {
+		contentProductsByID[contentProduct.ID] = contentProduct
+	}
+
+	err = domain.ValidateOrderLineComposition(product, productGroup, input.ContentLines, contentProductsByID)
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// repriceOrder replaces the price of every line in the order with the current
+// catalog price. Lines whose product no longer exists keep their price.
+func (s *OrderService) repriceOrder(ctx context.Context, order *domain.Order) error {
+	orderLines, err := s.orderRepository.GetOrderLinesByOrderId(ctx, order.ID)
+	if err != nil {
+		s.logger.Error("failed to get order lines by order ID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	productIDs := make([]uuid.UUID, len(orderLines))
+	for i, orderLine := range orderLines {
+		productIDs[i] = orderLine.ProductID
+	}
+
+	products, err := s.productRepository.GetProductsByIDs(ctx, productIDs)
+	if err != nil {
+		s.logger.Error("failed to get products by IDs", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	productsByID := make(map[uuid.UUID]domain.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+
+	for _, orderLine := range orderLines {
+		product, ok := productsByID[orderLine.ProductID]
+		if !ok {
+			continue
+		}
+
+		price := s.priceCalculator.UnitPrice(&product)
+		if price == orderLine.Price {
+			continue
+		}
+
+		orderLine.Price = price
+		err = s.orderRepository.UpdateOrderLine(ctx, orderLine)
+		if err != nil {
+			s.logger.Error("failed to update order line price", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserveOrderLineStock replaces the stock reservations of an order line.
+func (s *OrderService) reserveOrderLineStock(ctx context.Context, orderLine *domain.OrderLine, contentLines []*domain.OrderLineContentLine) error {
+	reservations := domain.CreateStockReservations(s.idGenerator.NewID, orderLine, contentLines, s.clock.Now().Add(s.reservationTTL))
+
+	err := s.inventoryRepository.ReserveStock(ctx, orderLine.ID, reservations, s.clock.Now())
+	if err != nil {
+		s.logger.Warn("failed to reserve stock for order line", map[string]interface{}{
+			"error":         err,
+			"order_line_id": orderLine.ID,
+		})
+		return err
+	}
+
+	return nil
+}
+
+// touchCart records activity on a cart, keeps its reservations alive while
+// the customer is changing it and reprices it. It fails when the order was checked out or
+// cancelled since it was read, which rolls back the change to the cart.
+// Callers run it in a transaction.
+func (s *OrderService) touchCart(ctx context.Context, order *domain.Order) error {
+	current, err := s.loadOrder(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	if !current.IsOpen() {
+		return domain.ErrOrderNotOpen
+	}
+
+	now := s.clock.Now()
+	order.Touch(now)
+	err = s.orderRepository.UpdateOrderLastActivity(ctx, order.ID, now)
+	if err != nil {
+		s.logger.Error("failed to update order last activity", map[string]interface{}{
+			"error":    err,
+			"order_id": order.ID,
+		})
+		return err
+	}
+
+	err = s.inventoryRepository.ExtendReservationsByOrderId(ctx, order.ID, now, now.Add(s.reservationTTL))
+	if err != nil {
+		s.logger.Error("failed to extend stock reservations", map[string]interface{}{
+			"error":    err,
+			"order_id": order.ID,
+		})
+		return err
+	}
+
+	err = s.reserveLapsedOrderLines(ctx, order, now)
+	if err != nil {
+		return err
+	}
+
+	return s.repriceOrder(ctx, order)
+}
+
+// reserveLapsedOrderLines reserves stock again for the lines of a cart whose
+// reservations lapsed while the customer was away. Lines there is no longer
+// stock for stay unreserved, checkout checks the stock again.
+func (s *OrderService) reserveLapsedOrderLines(ctx context.Context, order *domain.Order, now time.Time) error {
+	reservedOrderLineIDs, err := s.inventoryRepository.GetReservedOrderLineIds(ctx, order.ID, now)
+	if err != nil {
+		s.logger.Error("failed to get reserved order lines", map[string]interface{}{
+			"error":    err,
+			"order_id": order.ID,
+		})
+		return err
+	}
+
+	reserved := make(map[uuid.UUID]bool, len(reservedOrderLineIDs))
+	for _, orderLineID := range reservedOrderLineIDs {
+		reserved[orderLineID] = true
+	}
+
+	orderLines, err := s.orderRepository.GetOrderLinesByOrderId(ctx, order.ID)
+	if err != nil {
+		s.logger.Error("failed to get order lines by order ID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	for _, orderLine := range orderLines {
+		if reserved[orderLine.ID] {
+			continue
+		}
+
+		contentLines, err := s.orderRepository.GetOrderLineContentLinesByOrderLineId(ctx, orderLine.ID)
+		if err != nil {
+			s.logger.Error("failed to get order line content lines by order line ID", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		err = s.reserveOrderLineStock(ctx, orderLine, contentLines)
+		if err != nil && !errors.Is(err, domain.ErrInsufficientStock) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *OrderService) AddSessionOrderLine(ctx context.Context, sessionId string, input domain.AddOrderLineInput) (*DTOOrderDetails, error) {
+	order, err := s.getOpenSessionOrder(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.validateOrderLineComposition(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	orderLine, err := domain.CreateOrderLine(s.idGenerator.NewID(), domain.CreateOrderLineInput{
+		OrderID:   order.ID,
+		ProductID: input.ProductID,
+		Price:     s.priceCalculator.UnitPrice(product),
+		Quantity:  input.Quantity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contentLines := make([]*domain.OrderLineContentLine, len(input.ContentLines))
+	for i, contentLineInput := range input.ContentLines {
+		contentLines[i], err = domain.CreateOrderLineContentLine(s.idGenerator.NewID(), domain.CreateOrderLineContentLineInput{
+			OrderLineID: orderLine.ID,
+			ProductID:   contentLineInput.ProductID,
+			Quantity:    contentLineInput.Quantity,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		err := tx.reserveOrderLineStock(ctx, orderLine, contentLines)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.orderRepository.CreateOrderLine(ctx, orderLine)
+		if err != nil {
+			tx.logger.Error("failed to create order line", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		for _, contentLine := range contentLines {
+			_, err = tx.orderRepository.CreateOrderLineContentLine(ctx, contentLine)
+			if err != nil {
+				tx.logger.Error("failed to create order line content line", map[string]interface{}{
+					"error": err,
+				})
+				return err
+			}
+		}
+
+		return tx.touchCart(ctx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetOrderDetailsBySessionId(ctx, sessionId)
+}
+
+func (s *OrderService) UpdateSessionOrderLineQuantity(ctx context.Context, sessionId string, orderLineId string, input domain.UpdateOrderLineQuantityInput) (*DTOOrderDetails, error) {
+	order, err := s.getOpenSessionOrder(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	orderLine, err := s.getSessionOrderLine(ctx, order, orderLineId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = orderLine.UpdateQuantity(input.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	contentLines, err := s.orderRepository.GetOrderLineContentLinesByOrderLineId(ctx, orderLine.ID)
+	if err != nil {
+		s.logger.Error("failed to get order line content lines by order line ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		err := tx.reserveOrderLineStock(ctx, orderLine, contentLines)
+		if err != nil {
+			return err
+		}
+
+		err = tx.orderRepository.UpdateOrderLine(ctx, orderLine)
+		if err != nil {
+			tx.logger.Error("failed to update order line", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		return tx.touchCart(ctx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetOrderDetailsBySessionId(ctx, sessionId)
+}
+
+func (s *OrderService) RemoveSessionOrderLine(ctx context.Context, sessionId string, orderLineId string) (*DTOOrderDetails, error) {
+	order, err := s.getOpenSessionOrder(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	orderLine, err := s.getSessionOrderLine(ctx, order, orderLineId)
+	if err != nil {
+		return nil, err
+	}
+
+	contentLines, err := s.orderRepository.GetOrderLineContentLinesByOrderLineId(ctx, orderLine.ID)
+	if err != nil {
+		s.logger.Error("failed to get order line content lines by order line ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		for _, contentLine := range contentLines {
+			err := tx.orderRepository.DeleteOrderLineContentLine(ctx, contentLine.ID)
+			if err != nil {
+				tx.logger.Error("failed to delete order line content line", map[string]interface{}{
+					"error": err,
+				})
+				return err
+			}
+		}
+
+		err := tx.orderRepository.DeleteOrderLine(ctx, orderLine.ID)
+		if err != nil {
+			tx.logger.Error("failed to delete order line", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		err = tx.inventoryRepository.DeleteReservationsByOrderLineId(ctx, orderLine.ID)
+		if err != nil {
+			tx.logger.Error("failed to release stock reservations", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		return tx.touchCart(ctx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetOrderDetailsBySessionId(ctx, sessionId)
+}
+
+// AssignSessionOrderToCustomer makes the cart of a session the cart of a
+// customer who logged in. Carts the customer left open in other sessions are
+// merged into it, except for lines there is no longer stock for.
+func (s *OrderService) AssignSessionOrderToCustomer(ctx context.Context, sessionId string, customerID uuid.UUID) (*DTOOrderDetails, error) {
+	order, err := s.getOpenSessionOrder(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.inTransaction(ctx, func(tx *OrderService) error {
+		var err error
+		order, err = tx.loadOrder(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+
+		err = order.AssignCustomer(customerID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.orderRepository.UpdateOrder(ctx, order)
+		if err != nil {
+			tx.logger.Error("failed to update order", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		carts, _, err := tx.orderRepository.ListOrders(ctx, domain.OrderQuery{
+			CustomerID: &customerID,
+			Statuses:   []domain.OrderStatus{domain.OrderStatusCreated},
+			Sort:       domain.Sort{Field: "created_date_time"},
+			Page:       domain.Page{Limit: domain.MaxPageLimit},
+		})
+		if err != nil {
+			tx.logger.Error("failed to list open carts of customer", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		for _, cart := range carts {
+			if cart.ID == order.ID {
+				continue
+			}
+			err = tx.mergeCart(ctx, cart, order)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.touchCart(ctx, order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("assigned session order to customer", map[string]interface{}{
+		"order_id":    order.ID,
+		"customer_id": customerID,
+	})
+
+	return s.buildOrderDetails(ctx, order)
+}
+
+// mergeCart moves the lines of the cart from into the cart into and deletes
+// from. Lines that cannot be reserved again are dropped with it. Callers run
+// it in a transaction.
+func (s *OrderService) mergeCart(ctx context.Context, from *domain.Order, into *domain.Order) error {
+	orderLines, err := s.orderRepository.GetOrderLinesByOrderId(ctx, from.ID)
+	if err != nil {
+		s.logger.Error("failed to get order lines by order ID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	err = s.inventoryRepository.DeleteReservationsByOrderId(ctx, from.ID)
+	if err != nil {
+		s.logger.Error("failed to release stock reservations", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	for _, orderLine := range orderLines {
+		contentLines, err := s.orderRepository.GetOrderLineContentLinesByOrderLineId(ctx, orderLine.ID)
+		if err != nil {
+			s.logger.Error("failed to get order line content lines by order line ID", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		orderLine.OrderID = into.ID
+		err = s.reserveOrderLineStock(ctx, orderLine, contentLines)
+		if errors.Is(err, domain.ErrInsufficientStock) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.orderRepository.UpdateOrderLine(ctx, orderLine)
+		if err != nil {
+			s.logger.Error("failed to move order line", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+	}
+
+	return s.deleteOrder(ctx, from.ID)
+}
+
+// ListCustomerOrders finds the orders of a customer.
+func (s *OrderService) ListCustomerOrders(ctx context.Context, customerID uuid.UUID, query domain.OrderQuery) (*DTOOrderList, error) {
+	query.CustomerID = &customerID
+	return s.ListOrders(ctx, query)
+}
+
+// ReleaseExpiredReservations frees the stock held by carts that have not been
+// touched within the reservation TTL.
+func (s *OrderService) ReleaseExpiredReservations(ctx context.Context) error {
+	released, err := s.inventoryRepository.DeleteExpiredReservations(ctx, s.clock.Now())
+	if err != nil {
+		s.logger.Error("failed to release expired stock reservations", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+	if released > 0 {
+		s.logger.Info("released expired stock reservations", map[string]interface{}{
+			"count": released,
+		})
+	}
+
+	return nil
+}
+
+// cartCleanupBatchSize is the number of abandoned carts deleted per
+// transaction.
+const cartCleanupBatchSize = 100
+
+// DTOCartCleanup reports the outcome of a cart cleanup run.
+type DTOCartCleanup struct {
+	Removed int `json:"removed"`
+	Failed  int `json:"failed"`
+}
+
+// RemoveOldCreatedOrders deletes carts that have not been changed for the
+// abandoned cart age in batches. A batch that fails is retried one cart at a time, so a single bad
+// cart doesn't keep the others around. It returns an error when any cart
+// could not be removed, together with the report.
+func (s *OrderService) RemoveOldCreatedOrders(ctx context.Context) (*DTOCartCleanup, error) {
+	cutoff := s.clock.Now().Add(-s.abandonedCartAge)
+	report := &DTOCartCleanup{}
+	// Carts that could not be removed are left out of the following batches
+	var failed []uuid.UUID
+
+	for {
+		// A cancelled run stops between batches, the batch being deleted is
+		// finished first
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		deleteCtx := context.WithoutCancel(ctx)
+
+		batch, err := s.orderRepository.DeleteCreatedOrdersBefore(deleteCtx, cutoff, cartCleanupBatchSize, failed)
+		if err == nil {
+			report.Removed += len(batch)
+			if len(batch) < cartCleanupBatchSize {
+				break
+			}
+			continue
+		}
+
+		s.logger.Warn("failed to remove batch of old created orders, removing them one by one", map[string]interface{}{
+			"error": err,
+		})
+		ids, err := s.orderRepository.GetCreatedOrderIdsBefore(ctx, cutoff, cartCleanupBatchSize, failed)
+		if err != nil {
+			s.logger.Error("failed to get old created orders", map[string]interface{}{
+				"error": err,
+			})
+			return report, err
+		}
+		for _, id := range ids {
+			removed, err := s.orderRepository.DeleteCreatedOrders(deleteCtx, []uuid.UUID{id})
+			if err != nil {
+				failed = append(failed, id)
+				report.Failed++
+				s.logger.Error("failed to remove old created order", map[string]interface{}{
+					"error":    err,
+					"order_id": id,
+				})
+				continue
+			}
+			report.Removed += len(removed)
+		}
+
+		if len(ids) < cartCleanupBatchSize {
+			break
+		}
+	}
+
+	if report.Removed > 0 || report.Failed > 0 {
+		s.logger.Info("removed old created orders", map[string]interface{}{
+			"removed": report.Removed,
+			"failed":  report.Failed,
+		})
+	}
+	if report.Failed > 0 {
+		return report, fmt.Errorf("failed to remove %d of %d old created orders", report.Failed, report.Removed+report.Failed)
+	}
+	return report, nil
+}
@@ -0,0 +1,949 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// orderTest runs the order service against a fresh SQLite database, a fake
+// clock and the fake payment gateway.
+type orderTest struct {
+	db                  *gorm.DB
+	clock               *adapters.FakeClock
+	idGenerator         *adapters.SequentialIDGenerator
+	logger              ports.Logger
+	provider            *adapters.FakePaymentProvider
+	productRepository   *adapters.GormSLProductRepository
+	orderRepository     *adapters.GormSLOrderRepository
+	inventoryRepository *adapters.GormSLInventoryRepository
+	paymentRepository   *adapters.GormSLPaymentRepository
+	customerRepository  *adapters.GormSLCustomerRepository
+	jobRunRepository    *adapters.GormSLJobRunRepository
+	productService      *application.ProductService
+	orderService        *application.OrderService
+}
+
+var (
+	testCallbackSecret = []byte("test-callback-secret-of-32-bytes")
+	testStart          = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+)
+
+func newOrderTest(t *testing.T) *orderTest {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "commerce.db")+"?_txlock=immediate"), &gorm.Config{
+		TranslateError: true,
+		Logger:         gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := adapters.NewLogrusLogger()
+	logger.SetLogLevel("fatal")
+
+	test := &orderTest{
+		db:          db,
+		clock:       adapters.NewFakeClock(testStart),
+		idGenerator: adapters.NewSequentialIDGenerator(),
+		logger:      logger,
+		provider:    adapters.NewFakePaymentProvider(testCallbackSecret),
+	}
+	if test.productRepository, err = adapters.NewGormSLProductRepository(db, logger); err != nil {
+		t.Fatal(err)
+	}
+	if test.orderRepository, err = adapters.NewGormSLOrderRepository(db); err != nil {
+		t.Fatal(err)
+	}
+	if test.inventoryRepository, err = adapters.NewGormSLInventoryRepository(db); err != nil {
+		t.Fatal(err)
+	}
+	if test.paymentRepository, err = adapters.NewGormSLPaymentRepository(db); err != nil {
+		t.Fatal(err)
+	}
+	if test.customerRepository, err = adapters.NewGormSLCustomerRepository(db); err != nil {
+		t.Fatal(err)
+	}
+	if test.jobRunRepository, err = adapters.NewGormSLJobRunRepository(db); err != nil {
+		t.Fatal(err)
+	}
+	test.productService = application.NewProductService(
+		adapters.NewGormSLUnitOfWork(db),
+		test.productRepository,
+		test.inventoryRepository,
+		test.clock, test.idGenerator, logger)
+	test.orderService = test.newOrderService(t, test.provider, testPriceCalculator(t, 25, nil))
+
+	return test
+}
+
+func testPriceCalculator(t *testing.T, vatRatePercent int, discounts []domain.Discount) *domain.PriceCalculator {
+	t.Helper()
+
+	priceCalculator, err := domain.NewPriceCalculator(vatRatePercent, discounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priceCalculator
+}
+
+// newOrderService creates an order service on the database of the test, as
+// the server would after a restart.
+func (test *orderTest) newOrderService(t *testing.T, provider ports.PaymentProvider, priceCalculator *domain.PriceCalculator) *application.OrderService {
+	t.Helper()
+
+	return application.NewOrderService(
+		adapters.NewGormSLUnitOfWork(test.db),
+		test.orderRepository,
+		test.productRepository,
+		test.inventoryRepository,
+		test.paymentRepository,
+		provider,
+		priceCalculator,
+		10*time.Minute,
+		30*time.Minute,
+		test.clock,
+		test.idGenerator,
+		test.logger)
+}
+
+// createProduct adds a product sold on its own to the catalog.
+func (test *orderTest) createProduct(t *testing.T, price int) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+
+	productGroup, err := test.productService.CreateProductGroup(ctx, domain.CreateProductGroupInput{Name: "Bars", IsSold: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := test.productService.CreateProduct(ctx, domain.CreateProductInput{
+		Name:             "Dark bar",
+		Price:            price,
+		ProductGroupID:   productGroup.ProductGroup.ID,
+		IsSoldSeparately: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return product.Product.ID
+}
+
+// createCart creates the cart of a new session with quantity of the product.
+func (test *orderTest) createCart(t *testing.T, productID uuid.UUID, quantity int) string {
+	t.Helper()
+	ctx := context.Background()
+
+	sessionId := uuid.New()
+	if _, err := test.orderService.CreateSessionOrder(ctx, sessionId); err != nil {
+		t.Fatal(err)
+	}
+	_, err := test.orderService.AddSessionOrderLine(ctx, sessionId.String(), domain.AddOrderLineInput{
+		ProductID: productID,
+		Quantity:  quantity,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sessionId.String()
+}
+
+// checkoutCart creates a cart and checks it out.
+func (test *orderTest) checkoutCart(t *testing.T, productID uuid.UUID, quantity int) (string, *application.DTOOrderDetails) {
+	t.Helper()
+
+	sessionId := test.createCart(t, productID, quantity)
+	details, err := test.orderService.CheckoutSessionOrder(context.Background(), sessionId, domain.CheckoutInput{
+		Email:   "anna@example.com",
+		Name:    "Anna Andersson",
+		Address: "Storgatan 1",
+		ZipCode: "11122",
+		City:    "Stockholm",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sessionId, details
+}
+
+func (test *orderTest) orderStatus(t *testing.T, orderID uuid.UUID) domain.OrderStatus {
+	t.Helper()
+
+	order, err := test.orderService.GetOrderById(context.Background(), orderID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order.Status
+}
+
+func (test *orderTest) paymentStatus(t *testing.T, orderID uuid.UUID) domain.PaymentStatus {
+	t.Helper()
+
+	payments, err := test.orderService.GetOrderPayments(context.Background(), orderID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments.Payments) != 1 {
+		t.Fatalf("order has %d payments, want 1", len(payments.Payments))
+	}
+	return payments.Payments[0].Status
+}
+
+func TestPaymentIsCapturedAndRefunded(t *testing.T) {
+	ctx := context.Background()
+	test := newOrderTest(t)
+	productID := test.createProduct(t, 5000)
+	sessionId, details := test.checkoutCart(t, productID, 2)
+	orderID := details.Order.ID
+
+	payment, err := test.orderService.StartSessionPayment(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Payment.Amount != 10000 {
+		t.Fatalf("payment amount = %d, want 10000", payment.Payment.Amount)
+	}
+
+	body, headers := test.provider.Callback(payment.Payment.ProviderReference, payment.Payment.Amount, true)
+	if err := test.orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+		t.Fatal(err)
+	}
+	if status := test.orderStatus(t, orderID); status != domain.OrderStatusPaid {
+		t.Fatalf("order status after callback = %s, want paid", status)
+	}
+	if status := test.paymentStatus(t, orderID); status != domain.PaymentStatusCaptured {
+		t.Fatalf("payment status after callback = %s, want captured", status)
+	}
+
+	// The gateway retries callbacks, a repeated one changes nothing
+	if err := test.orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+		t.Fatalf("repeated callback: %v", err)
+	}
+
+	if _, err := test.orderService.RefundOrder(ctx, orderID.String(), domain.ActorStaff); err != nil {
+		t.Fatal(err)
+	}
+	if status := test.orderStatus(t, orderID); status != domain.OrderStatusRefunded {
+		t.Fatalf("order status after refund = %s, want refunded", status)
+	}
+	if status := test.paymentStatus(t, orderID); status != domain.PaymentStatusRefunded {
+		t.Fatalf("payment status after refund = %s, want refunded", status)
+	}
+}
+
+func TestPaymentCallbacks(t *testing.T) {
+	otherProvider := adapters.NewFakePaymentProvider([]byte("some-other-secret-of-32-bytes..."))
+
+	tests := []struct {
+		name              string
+		callback          func(provider *adapters.FakePaymentProvider, payment domain.Payment) ([]byte, map[string]string)
+		wantErr           error
+		wantOrderStatus   domain.OrderStatus
+		wantPaymentStatus domain.PaymentStatus
+	}{
+		{
+			name: "declined",
+			callback: func(provider *adapters.FakePaymentProvider, payment domain.Payment) ([]byte, map[string]string) {
+				return provider.Callback(payment.ProviderReference, payment.Amount, false)
+			},
+			wantOrderStatus:   domain.OrderStatusCheckout,
+			wantPaymentStatus: domain.PaymentStatusFailed,
+		},
+		{
+			name: "unsigned",
+			callback: func(provider *adapters.FakePaymentProvider, payment domain.Payment) ([]byte, map[string]string) {
+				body, _ := provider.Callback(payment.ProviderReference, payment.Amount, true)
+				return body, map[string]string{}
+			},
+			wantErr:           domain.ErrUnauthenticated,
+			wantOrderStatus:   domain.OrderStatusCheckout,
+			wantPaymentStatus: domain.PaymentStatusPending,
+		},
+		{
+			name: "signed with another secret",
+			callback: func(provider *adapters.FakePaymentProvider, payment domain.Payment) ([]byte, map[string]string) {
+				return otherProvider.Callback(payment.ProviderReference, payment.Amount, true)
+			},
+			wantErr:           domain.ErrUnauthenticated,
+			wantOrderStatus:   domain.OrderStatusCheckout,
+			wantPaymentStatus: domain.PaymentStatusPending,
+		},
+		{
+			name: "amount does not match",
+			callback: func(provider *adapters.FakePaymentProvider, payment domain.Payment) ([]byte, map[string]string) {
+				return provider.Callback(payment.ProviderReference, 1, true)
+			},
+			wantErr:           domain.ErrInvalidInput,
+			wantOrderStatus:   domain.OrderStatusCheckout,
+			wantPaymentStatus: domain.PaymentStatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			productID := test.createProduct(t, 5000)
+			sessionId, details := test.checkoutCart(t, productID, 1)
+
+			payment, err := test.orderService.StartSessionPayment(ctx, sessionId)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, headers := tt.callback(test.provider, payment.Payment)
+			err = test.orderService.HandlePaymentCallback(ctx, "fake", body, headers)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			if status := test.orderStatus(t, details.Order.ID); status != tt.wantOrderStatus {
+				t.Errorf("order status = %s, want %s", status, tt.wantOrderStatus)
+			}
+			if status := test.paymentStatus(t, details.Order.ID); status != tt.wantPaymentStatus {
+				t.Errorf("payment status = %s, want %s", status, tt.wantPaymentStatus)
+			}
+		})
+	}
+}
+
+func TestPaymentStartedBeforeRestartIsCaptured(t *testing.T) {
+	ctx := context.Background()
+	test := newOrderTest(t)
+	productID := test.createProduct(t, 5000)
+
+	sessionId, details := test.checkoutCart(t, productID, 1)
+	before, err := test.orderService.StartSessionPayment(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new gateway with the same secret has never heard of the payment
+	restarted := adapters.NewFakePaymentProvider(testCallbackSecret)
+	orderService := test.newOrderService(t, restarted, testPriceCalculator(t, 25, nil))
+
+	otherSessionId, _ := test.checkoutCart(t, productID, 1)
+	if _, err := orderService.StartSessionPayment(ctx, otherSessionId); err != nil {
+		t.Fatalf("starting a payment after the restart: %v", err)
+	}
+
+	body, headers := restarted.Callback(before.Payment.ProviderReference, before.Payment.Amount, true)
+	if err := orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+		t.Fatal(err)
+	}
+	if status := test.orderStatus(t, details.Order.ID); status != domain.OrderStatusPaid {
+		t.Fatalf("order status = %s, want paid", status)
+	}
+}
+
+func TestStartSessionPaymentReusesPendingPayment(t *testing.T) {
+	ctx := context.Background()
+	test := newOrderTest(t)
+	productID := test.createProduct(t, 5000)
+	sessionId, details := test.checkoutCart(t, productID, 1)
+
+	first, err := test.orderService.StartSessionPayment(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := test.orderService.StartSessionPayment(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Payment.ID != first.Payment.ID {
+		t.Errorf("second payment = %s, want the pending %s", second.Payment.ID, first.Payment.ID)
+	}
+	if status := test.paymentStatus(t, details.Order.ID); status != domain.PaymentStatusPending {
+		t.Errorf("payment status = %s, want pending", status)
+	}
+
+	body, headers := test.provider.Callback(first.Payment.ProviderReference, first.Payment.Amount, true)
+	if err := test.orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := test.orderService.StartSessionPayment(ctx, sessionId); !errors.Is(err, domain.ErrOrderNotAwaitingPayment) {
+		t.Errorf("starting a payment of a paid order: error = %v, want %v", err, domain.ErrOrderNotAwaitingPayment)
+	}
+}
+
+func TestAuthorizedPaymentOfOrderNotAwaitingPaymentIsVoided(t *testing.T) {
+	tests := []struct {
+		name            string
+		settle          func(t *testing.T, test *orderTest, orderID uuid.UUID)
+		wantOrderStatus domain.OrderStatus
+	}{
+		{
+			name: "cancelled",
+			settle: func(t *testing.T, test *orderTest, orderID uuid.UUID) {
+				if _, err := test.orderService.CancelOrder(context.Background(), orderID.String(), domain.ActorStaff); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantOrderStatus: domain.OrderStatusCancelled,
+		},
+		{
+			name: "paid through another payment",
+			settle: func(t *testing.T, test *orderTest, orderID uuid.UUID) {
+				// An earlier release started a new payment on every request
+				ctx := context.Background()
+				intent, err := test.provider.CreatePaymentIntent(ctx, nil, 5000, domain.CurrencySEK)
+				if err != nil {
+					t.Fatal(err)
+				}
+				other, err := domain.CreatePayment(uuid.New(), orderID, "fake", intent, 5000, domain.CurrencySEK, test.clock.Now())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := test.paymentRepository.CreatePayment(ctx, other); err != nil {
+					t.Fatal(err)
+				}
+				body, headers := test.provider.Callback(other.ProviderReference, other.Amount, true)
+				if err := test.orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantOrderStatus: domain.OrderStatusPaid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			productID := test.createProduct(t, 5000)
+			sessionId, details := test.checkoutCart(t, productID, 1)
+
+			payment, err := test.orderService.StartSessionPayment(ctx, sessionId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.settle(t, test, details.Order.ID)
+
+			body, headers := test.provider.Callback(payment.Payment.ProviderReference, payment.Payment.Amount, true)
+			if err := test.orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+				t.Fatal(err)
+			}
+
+			if status := test.orderStatus(t, details.Order.ID); status != tt.wantOrderStatus {
+				t.Errorf("order status = %s, want %s", status, tt.wantOrderStatus)
+			}
+			payments, err := test.orderService.GetOrderPayments(ctx, details.Order.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status := payments.Payments[0].Status; status != domain.PaymentStatusVoided {
+				t.Errorf("payment status = %s, want voided", status)
+			}
+			// The payment is no longer authorized with the gateway
+			err = test.provider.Capture(ctx, payment.Payment.ProviderReference, payment.Payment.Amount, "capture-after-void")
+			if !errors.Is(err, domain.ErrConflict) {
+				t.Errorf("capturing the voided payment: error = %v, want %v", err, domain.ErrConflict)
+			}
+		})
+	}
+}
+
+// failingPaymentProvider fails the first capture and refund, as if the
+// gateway was unreachable.
+type failingPaymentProvider struct {
+	*adapters.FakePaymentProvider
+	captureFailed bool
+	refundFailed  bool
+}
+
+var errGatewayUnavailable = errors.New("gateway unavailable")
+
+func (p *failingPaymentProvider) Capture(ctx context.Context, reference string, amount int, idempotencyKey string) error {
+	if !p.captureFailed {
+		p.captureFailed = true
+		return errGatewayUnavailable
+	}
+	return p.FakePaymentProvider.Capture(ctx, reference, amount, idempotencyKey)
+}
+
+func (p *failingPaymentProvider) Refund(ctx context.Context, reference string, amount int, idempotencyKey string) error {
+	if !p.refundFailed {
+		p.refundFailed = true
+		return errGatewayUnavailable
+	}
+	return p.FakePaymentProvider.Refund(ctx, reference, amount, idempotencyKey)
+}
+
+func TestFailedProviderCallsAreRetried(t *testing.T) {
+	ctx := context.Background()
+	test := newOrderTest(t)
+	orderService := test.newOrderService(t, &failingPaymentProvider{FakePaymentProvider: test.provider}, testPriceCalculator(t, 25, nil))
+	productID := test.createProduct(t, 5000)
+	sessionId, details := test.checkoutCart(t, productID, 1)
+	orderID := details.Order.ID
+
+	payment, err := orderService.StartSessionPayment(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, headers := test.provider.Callback(payment.Payment.ProviderReference, payment.Payment.Amount, true)
+	if err := orderService.HandlePaymentCallback(ctx, "fake", body, headers); !errors.Is(err, errGatewayUnavailable) {
+		t.Fatalf("callback: error = %v, want %v", err, errGatewayUnavailable)
+	}
+	if status := test.orderStatus(t, orderID); status != domain.OrderStatusPaid {
+		t.Fatalf("order status after failed capture = %s, want paid", status)
+	}
+	if status := test.paymentStatus(t, orderID); status != domain.PaymentStatusCapturing {
+		t.Fatalf("payment status after failed capture = %s, want capturing", status)
+	}
+
+	// The gateway retries the callback
+	if err := orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+		t.Fatal(err)
+	}
+	if status := test.paymentStatus(t, orderID); status != domain.PaymentStatusCaptured {
+		t.Fatalf("payment status after retried callback = %s, want captured", status)
+	}
+
+	if _, err := orderService.RefundOrder(ctx, orderID.String(), domain.ActorStaff); !errors.Is(err, errGatewayUnavailable) {
+		t.Fatalf("refund: error = %v, want %v", err, errGatewayUnavailable)
+	}
+	if status := test.orderStatus(t, orderID); status != domain.OrderStatusRefunded {
+		t.Fatalf("order status after failed refund = %s, want refunded", status)
+	}
+	if status := test.paymentStatus(t, orderID); status != domain.PaymentStatusRefunding {
+		t.Fatalf("payment status after failed refund = %s, want refunding", status)
+	}
+
+	if _, err := orderService.RefundOrder(ctx, orderID.String(), domain.ActorStaff); err != nil {
+		t.Fatal(err)
+	}
+	if status := test.paymentStatus(t, orderID); status != domain.PaymentStatusRefunded {
+		t.Fatalf("payment status after retried refund = %s, want refunded", status)
+	}
+	if _, err := orderService.RefundOrder(ctx, orderID.String(), domain.ActorStaff); !errors.Is(err, domain.ErrIllegalStatusTransition) {
+		t.Errorf("refunding a refunded order: error = %v, want %v", err, domain.ErrIllegalStatusTransition)
+	}
+}
+
+func (test *orderTest) stockQuantity(t *testing.T, productID uuid.UUID) int {
+	t.Helper()
+
+	stock, err := test.productService.GetProductStock(context.Background(), productID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stock.StockLevel.Quantity
+}
+
+func TestConcurrentCancelsReleaseStockOnce(t *testing.T) {
+	ctx := context.Background()
+	test := newOrderTest(t)
+	productID := test.createProduct(t, 5000)
+	if _, err := test.productService.SetProductStock(ctx, productID.String(), domain.SetStockLevelInput{Quantity: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, details := test.checkoutCart(t, productID, 2)
+	if quantity := test.stockQuantity(t, productID); quantity != 8 {
+		t.Fatalf("stock after checkout = %d, want 8", quantity)
+	}
+
+	const cancels = 4
+	errs := make(chan error, cancels)
+	for range cancels {
+		go func() {
+			_, err := test.orderService.CancelOrder(ctx, details.Order.ID.String(), domain.ActorStaff)
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for range cancels {
+		err := <-errs
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, domain.ErrIllegalStatusTransition):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d cancels succeeded, want 1", succeeded)
+	}
+	if quantity := test.stockQuantity(t, productID); quantity != 10 {
+		t.Errorf("stock after cancelling = %d, want 10", quantity)
+	}
+}
+
+func TestCheckedOutOrderKeepsCheckoutPrices(t *testing.T) {
+	ctx := context.Background()
+	test := newOrderTest(t)
+	productID := test.createProduct(t, 5000)
+	sessionId, _ := test.checkoutCart(t, productID, 2)
+	cartSessionId := test.createCart(t, productID, 1)
+
+	// The catalog and the pricing rules change after checkout
+	name, price := "Renamed bar", 7000
+	_, err := test.productService.UpdateProduct(ctx, productID.String(), domain.UpdateProductInput{Name: &name, Price: &price})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderService := test.newOrderService(t, test.provider, testPriceCalculator(t, 12, []domain.Discount{{Name: "Half off", PercentOff: 50}}))
+
+	details, err := orderService.GetOrderDetailsBySessionId(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := details.Order
+	if order.Subtotal != 10000 || order.Discount != 0 || order.VAT != 2000 || order.Total != 10000 {
+		t.Errorf("amounts = %d/%d/%d/%d, want the checkout amounts 10000/0/2000/10000", order.Subtotal, order.Discount, order.VAT, order.Total)
+	}
+	line := order.OrderLines[0]
+	if line.Product.Name != "Dark bar" || line.Product.Price != 5000 || line.UnitPrice != 5000 || line.LineTotal != 10000 {
+		t.Errorf("line = %q at %d (%d, %d), want Dark bar at 5000 (5000, 10000)", line.Product.Name, line.Product.Price, line.UnitPrice, line.LineTotal)
+	}
+
+	payment, err := orderService.StartSessionPayment(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Payment.Amount != 10000 {
+		t.Errorf("payment amount = %d, want 10000", payment.Payment.Amount)
+	}
+
+	// An open cart shows the current product, prices and pricing rules, as
+	// checkout will charge them
+	cart, err := orderService.GetOrderDetailsBySessionId(ctx, cartSessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cartLine := cart.Order.OrderLines[0]
+	if cartLine.Product.Name != name || cartLine.Product.Price != price || cartLine.UnitPrice != price || cart.Order.Total != 3500 {
+		t.Errorf("cart = %q at %d (%d) with total %d, want %q at %d (%d) with total 3500", cartLine.Product.Name, cartLine.Product.Price, cartLine.UnitPrice, cart.Order.Total, name, price, price)
+	}
+	checkedOut, err := orderService.CheckoutSessionOrder(ctx, cartSessionId, domain.CheckoutInput{
+		Email:   "anna@example.com",
+		Name:    "Anna Andersson",
+		Address: "Storgatan 1",
+		ZipCode: "11122",
+		City:    "Stockholm",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkedOut.Order.Total != cart.Order.Total {
+		t.Errorf("checked out total = %d, want the cart total %d", checkedOut.Order.Total, cart.Order.Total)
+	}
+}
+
+func TestDeleteOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    domain.OrderStatus
+		wantErr   error
+		wantStock int
+	}{
+		{name: "cart", status: domain.OrderStatusCreated, wantStock: 10},
+		{name: "checked out", status: domain.OrderStatusCheckout, wantErr: domain.ErrOrderNotDeletable, wantStock: 8},
+		{name: "paid", status: domain.OrderStatusPaid, wantErr: domain.ErrOrderNotDeletable, wantStock: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			productID := test.createProduct(t, 5000)
+			if _, err := test.productService.SetProductStock(ctx, productID.String(), domain.SetStockLevelInput{Quantity: 10}); err != nil {
+				t.Fatal(err)
+			}
+
+			var sessionId string
+			if tt.status == domain.OrderStatusCreated {
+				sessionId = test.createCart(t, productID, 2)
+			} else {
+				sessionId, _ = test.checkoutCart(t, productID, 2)
+			}
+			if tt.status == domain.OrderStatusPaid {
+				payment, err := test.orderService.StartSessionPayment(ctx, sessionId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, headers := test.provider.Callback(payment.Payment.ProviderReference, payment.Payment.Amount, true)
+				if err := test.orderService.HandlePaymentCallback(ctx, "fake", body, headers); err != nil {
+					t.Fatal(err)
+				}
+			}
+			details, err := test.orderService.GetOrderDetailsBySessionId(ctx, sessionId)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = test.orderService.DeleteOrder(ctx, details.Order.ID.String())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, err = test.orderService.GetOrderById(ctx, details.Order.ID.String())
+			if deleted := errors.Is(err, domain.ErrOrderNotFound); deleted != (tt.wantErr == nil) {
+				t.Errorf("order deleted = %t, want %t", deleted, tt.wantErr == nil)
+			}
+			if quantity := test.stockQuantity(t, productID); quantity != tt.wantStock {
+				t.Errorf("stock = %d, want %d", quantity, tt.wantStock)
+			}
+			// A deleted cart releases its reservations, checkout consumed them
+			reserved, err := test.inventoryRepository.GetReservedQuantities(ctx, []uuid.UUID{productID}, test.clock.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reserved[productID] != 0 {
+				t.Errorf("reserved = %d, want 0", reserved[productID])
+			}
+		})
+	}
+}
+
+func TestConcurrentSessionOrdersCreateOneOrder(t *testing.T) {
+	ctx := context.Background()
+	test := newOrderTest(t)
+	sessionId := uuid.New()
+
+	const requests = 4
+	errs := make(chan error, requests)
+	for range requests {
+		go func() {
+			_, err := test.orderService.CreateSessionOrder(ctx, sessionId)
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for range requests {
+		err := <-errs
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, domain.ErrSessionHasOrder):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d orders created, want 1", succeeded)
+	}
+}
+
+func TestRemoveOldCreatedOrders(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldCarts    int
+		failing     []int // indexes of old carts that can't be deleted
+		wantRemoved int
+		wantFailed  int
+	}{
+		{name: "no old carts", oldCarts: 0},
+		{name: "one batch", oldCarts: 40, wantRemoved: 40},
+		{name: "several batches", oldCarts: 250, wantRemoved: 250},
+		{name: "failing cart", oldCarts: 150, failing: []int{0}, wantRemoved: 149, wantFailed: 1},
+		{name: "failing carts in several batches", oldCarts: 250, failing: []int{3, 120, 249}, wantRemoved: 247, wantFailed: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			productID := test.createProduct(t, 5000)
+
+			// The old carts share their creation time, so only the ID orders them
+			oldCarts := make([]uuid.UUID, tt.oldCarts)
+			for i := range oldCarts {
+				order, err := test.orderService.CreateSessionOrder(ctx, uuid.New())
+				if err != nil {
+					t.Fatal(err)
+				}
+				oldCarts[i] = order.ID
+			}
+			_, checkedOut := test.checkoutCart(t, productID, 1)
+			for _, i := range tt.failing {
+				trigger := fmt.Sprintf(`CREATE TRIGGER keep_cart_%d BEFORE DELETE ON db_orders WHEN old.id = '%s'
+					BEGIN SELECT RAISE(ABORT, 'cart is locked'); END`, i, oldCarts[i])
+				if err := test.db.Exec(trigger).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			test.clock.Advance(time.Hour)
+			recentSessionId := test.createCart(t, productID, 1)
+
+			report, err := test.orderService.RemoveOldCreatedOrders(ctx)
+			if (err != nil) != (tt.wantFailed > 0) {
+				t.Errorf("error = %v, want an error %t", err, tt.wantFailed > 0)
+			}
+			if report.Removed != tt.wantRemoved || report.Failed != tt.wantFailed {
+				t.Errorf("removed %d and failed %d, want %d and %d", report.Removed, report.Failed, tt.wantRemoved, tt.wantFailed)
+			}
+
+			var remaining int64
+			if err := test.db.Table("db_orders").Count(&remaining).Error; err != nil {
+				t.Fatal(err)
+			}
+			if want := int64(tt.wantFailed + 2); remaining != want {
+				t.Errorf("%d orders left, want %d", remaining, want)
+			}
+			if status := test.orderStatus(t, checkedOut.Order.ID); status != domain.OrderStatusCheckout {
+				t.Errorf("checked out order status = %s, want checkout", status)
+			}
+			if _, err := test.orderService.GetOrderDetailsBySessionId(ctx, recentSessionId); err != nil {
+				t.Errorf("recent cart: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoveOldCreatedOrdersKeepsActiveCarts(t *testing.T) {
+	tests := []struct {
+		name        string
+		changedAt   time.Duration // after the cart was created, 0 for never
+		wantRemoved bool
+	}{
+		{name: "never changed", wantRemoved: true},
+		{name: "changed long ago", changedAt: 5 * time.Minute, wantRemoved: true},
+		{name: "changed recently", changedAt: 20 * time.Minute, wantRemoved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			productID := test.createProduct(t, 5000)
+
+			sessionId := uuid.New()
+			order, err := test.orderService.CreateSessionOrder(ctx, sessionId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.changedAt > 0 {
+				test.clock.Advance(tt.changedAt)
+				_, err := test.orderService.AddSessionOrderLine(ctx, sessionId.String(), domain.AddOrderLineInput{ProductID: productID, Quantity: 1})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The abandoned cart age is 30 minutes
+			test.clock.Set(testStart.Add(40 * time.Minute))
+			if _, err := test.orderService.RemoveOldCreatedOrders(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = test.orderService.GetOrderById(ctx, order.ID.String())
+			if removed := errors.Is(err, domain.ErrOrderNotFound); removed != tt.wantRemoved {
+				t.Errorf("cart removed = %t, want %t", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestTouchingCartReservesLapsedLinesAgain(t *testing.T) {
+	tests := []struct {
+		name         string
+		otherCart    int // quantity taken by another cart while the holds are lapsed
+		wantReserved int
+	}{
+		{name: "stock left", otherCart: 0, wantReserved: 2},
+		{name: "stock taken by another cart", otherCart: 9, wantReserved: 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			productID := test.createProduct(t, 5000)
+			if _, err := test.productService.SetProductStock(ctx, productID.String(), domain.SetStockLevelInput{Quantity: 10}); err != nil {
+				t.Fatal(err)
+			}
+			untrackedProductID := test.createProduct(t, 1000)
+			sessionId := test.createCart(t, productID, 2)
+
+			// The reservation TTL is 10 minutes
+			test.clock.Advance(15 * time.Minute)
+			if tt.otherCart > 0 {
+				test.createCart(t, productID, tt.otherCart)
+			}
+
+			_, err := test.orderService.AddSessionOrderLine(ctx, sessionId, domain.AddOrderLineInput{ProductID: untrackedProductID, Quantity: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reserved, err := test.inventoryRepository.GetReservedQuantities(ctx, []uuid.UUID{productID}, test.clock.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reserved[productID] != tt.wantReserved {
+				t.Errorf("reserved = %d, want %d", reserved[productID], tt.wantReserved)
+			}
+		})
+	}
+}
+
+func TestAddSessionOrderLineRejectsProductsNotForSale(t *testing.T) {
+	tests := []struct {
+		name             string
+		groupIsSold      bool
+		isSoldSeparately bool
+		wantErr          error
+	}{
+		{name: "for sale", groupIsSold: true, isSoldSeparately: true},
+		{name: "only sold as content", groupIsSold: true, isSoldSeparately: false, wantErr: domain.ErrValidation},
+		{name: "product group not sold", groupIsSold: false, isSoldSeparately: true, wantErr: domain.ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			test := newOrderTest(t)
+			productGroup, err := test.productService.CreateProductGroup(ctx, domain.CreateProductGroupInput{Name: "Pralines", IsSold: tt.groupIsSold})
+			if err != nil {
+				t.Fatal(err)
+			}
+			product, err := test.productService.CreateProduct(ctx, domain.CreateProductInput{
+				Name:             "Hazelnut praline",
+				Price:            1500,
+				ProductGroupID:   productGroup.ProductGroup.ID,
+				IsSoldSeparately: tt.isSoldSeparately,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sessionId := uuid.New()
+			if _, err := test.orderService.CreateSessionOrder(ctx, sessionId); err != nil {
+				t.Fatal(err)
+			}
+			_, err = test.orderService.AddSessionOrderLine(ctx, sessionId.String(), domain.AddOrderLineInput{
+				ProductID: product.Product.ID,
+				Quantity:  1,
+			})
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
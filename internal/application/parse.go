@@ -0,0 +1,15 @@
+package application
+
+import (
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// parseID parses an ID given by a client.
+func parseID(id string) (uuid.UUID, error) {
+	uuidId, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, domain.Errorf(domain.ErrInvalidInput, "invalid ID %q", id)
+	}
+	return uuidId, nil
+}
@@ -1,14 +1,22 @@
 package application
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/google/uuid"
 	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
 	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
 )
 
 type ProductService struct {
-	productRepository ports.ProductRepository
-	logger            ports.Logger
+	unitOfWork          ports.UnitOfWork
+	productRepository   ports.ProductRepository
+	inventoryRepository ports.InventoryRepository
+	clock               ports.Clock
+	idGenerator         ports.IDGenerator
+	logger              ports.Logger
 }
 
 type DTOProductGroupDetails struct {
@@ -19,27 +27,76 @@ type DTOProductDetails struct {
 	Product domain.Product `json:"product"`
 }
 
+// DTOPagination describes the page of a list that was returned.
+type DTOPagination struct {
+	Offset int   `json:"offset"`
+	Limit  int   `json:"limit"`
+	Total  int64 `json:"total"`
+}
+
+func newDTOPagination(page domain.Page, total int64) *DTOPagination {
+	return &DTOPagination{Offset: page.Offset, Limit: page.Limit, Total: total}
+}
+
 type DTOProductGroupList struct {
 	ProductGroups []domain.ProductGroup `json:"product_groups"`
+	Pagination    *DTOPagination        `json:"pagination,omitempty"`
 }
 
 type DTOProductList struct {
-	Products []domain.Product `json:"products"`
+	Products   []domain.Product `json:"products"`
+	Pagination *DTOPagination   `json:"pagination,omitempty"`
 }
 
 type DTOProductGroupWithProducts struct {
-	ProductGroups []domain.ProductGroupWithProducts `json:"product_groups"`
+	ProductGroups []DTOProductGroupWithAvailableProducts `json:"product_groups"`
+}
+
+type DTOProductGroupWithAvailableProducts struct {
+	ProductGroup domain.ProductGroup   `json:"product_group"`
+	Products     []DTOAvailableProduct `json:"products"`
+}
+
+type DTOAvailableProduct struct {
+	domain.Product
+	domain.ProductAvailability
 }
 
-func NewProductService(productRepository ports.ProductRepository, logger ports.Logger) *ProductService {
+type DTOProductStock struct {
+	ProductID    uuid.UUID                  `json:"product_id"`
+	StockLevel   *domain.StockLevel         `json:"stock_level"`
+	Availability domain.ProductAvailability `json:"availability"`
+}
+
+func NewProductService(unitOfWork ports.UnitOfWork, productRepository ports.ProductRepository, inventoryRepository ports.InventoryRepository, clock ports.Clock, idGenerator ports.IDGenerator, logger ports.Logger) *ProductService {
 	return &ProductService{
-		productRepository: productRepository,
-		logger:            logger,
+		unitOfWork:          unitOfWork,
+		productRepository:   productRepository,
+		inventoryRepository: inventoryRepository,
+		clock:               clock,
+		idGenerator:         idGenerator,
+		logger:              logger,
 	}
 }
 
-func (s *ProductService) GetProductGroups() (*DTOProductGroupList, error) {
-	productGroups, err := s.productRepository.ListProductGroups()
+// inTransaction runs fn with a copy of the service whose repositories all take
+// part in the same transaction.
+func (s *ProductService) inTransaction(ctx context.Context, fn func(tx *ProductService) error) error {
+	return s.unitOfWork.Do(ctx, func(repositories ports.Repositories) error {
+		tx := *s
+		tx.productRepository = repositories.Products()
+		tx.inventoryRepository = repositories.Inventory()
+		return fn(&tx)
+	})
+}
+
+func (s *ProductService) GetProductGroups(ctx context.Context, query domain.ProductGroupQuery) (*DTOProductGroupList, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	productGroups, total, err := s.productRepository.ListProductGroups(ctx, query)
 	if err != nil {
 		s.logger.Error("failed to get product groups", map[string]interface{}{
 			"error": err,
@@ -47,16 +104,16 @@ func (s *ProductService) GetProductGroups() (*DTOProductGroupList, error) {
 		return nil, err
 	}
 
-	return &DTOProductGroupList{ProductGroups: productGroups}, nil
+	return &DTOProductGroupList{ProductGroups: productGroups, Pagination: newDTOPagination(query.Page, total)}, nil
 }
 
-func (s *ProductService) CreateProductGroup(productGroupInput domain.CreateProductGroupInput) (*DTOProductGroupDetails, error) {
-	productGroup, err := domain.CreateProductGroup(productGroupInput)
+func (s *ProductService) CreateProductGroup(ctx context.Context, productGroupInput domain.CreateProductGroupInput) (*DTOProductGroupDetails, error) {
+	productGroup, err := domain.CreateProductGroup(s.idGenerator.NewID(), productGroupInput)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.productRepository.CreateProductGroup(productGroup)
+	err = s.productRepository.CreateProductGroup(ctx, productGroup)
 	if err != nil {
 		s.logger.Error("failed to create product group", map[string]interface{}{
 			"error": err,
@@ -67,8 +124,8 @@ func (s *ProductService) CreateProductGroup(productGroupInput domain.CreateProdu
 	return &DTOProductGroupDetails{ProductGroup: *productGroup}, nil
 }
 
-func (s *ProductService) GetProductGroupByID(id string) (*DTOProductGroupDetails, error) {
-	uuidId, err := uuid.Parse(id)
+func (s *ProductService) GetProductGroupByID(ctx context.Context, id string) (*DTOProductGroupDetails, error) {
+	uuidId, err := parseID(id)
 	if err != nil {
 		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
@@ -76,7 +133,7 @@ func (s *ProductService) GetProductGroupByID(id string) (*DTOProductGroupDetails
 		return nil, err
 	}
 
-	productGroup, err := s.productRepository.GetProductGroup(uuidId)
+	productGroup, err := s.productRepository.GetProductGroup(ctx, uuidId)
 	if err != nil {
 		s.logger.Error("failed to get product group by ID", map[string]interface{}{
 			"error": err,
@@ -87,13 +144,270 @@ func (s *ProductService) GetProductGroupByID(id string) (*DTOProductGroupDetails
 	return &DTOProductGroupDetails{ProductGroup: *productGroup}, nil
 }
 
-func (s *ProductService) CreateProduct(productInput domain.CreateProductInput) (*DTOProductDetails, error) {
-	product, err := domain.CreateProduct(productInput)
+func (s *ProductService) UpdateProductGroup(ctx context.Context, id string, productGroupInput domain.UpdateProductGroupInput) (*DTOProductGroupDetails, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	productGroup, err := s.productRepository.GetProductGroup(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to get product group by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	err = productGroup.Update(productGroupInput)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.productRepository.UpdateProductGroup(ctx, productGroup)
+	if err != nil {
+		s.logger.Error("failed to update product group", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOProductGroupDetails{ProductGroup: *productGroup}, nil
+}
+
+// DeleteProductGroup deletes a product group. A group that products belong to
+// or that configures products is only deleted when the input says what to do
+// with those products.
+func (s *ProductService) DeleteProductGroup(ctx context.Context, id string, input domain.DeleteProductGroupInput) error {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	err = input.Validate(uuidId)
+	if err != nil {
+		return err
+	}
+
+	return s.inTransaction(ctx, func(tx *ProductService) error {
+		return tx.deleteProductGroup(ctx, uuidId, input)
+	})
+}
+
+func (s *ProductService) deleteProductGroup(ctx context.Context, id uuid.UUID, input domain.DeleteProductGroupInput) error {
+	_, err := s.productRepository.GetProductGroup(ctx, id)
+	if err != nil {
+		s.logger.Error("failed to get product group by ID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	members, err := s.productRepository.ListProductsByProductGroupID(ctx, id)
+	if err != nil {
+		s.logger.Error("failed to get products by product group ID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	configured, err := s.productRepository.ListProductsByConfiguringProductGroupID(ctx, id)
+	if err != nil {
+		s.logger.Error("failed to get products by configuring product group ID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	switch {
+	case len(members) == 0 && len(configured) == 0:
+	case input.ReassignTo != nil:
+		err = s.reassignProducts(ctx, id, *input.ReassignTo, append(members, configured...))
+	case input.Cascade:
+		err = s.cascadeProductGroupDeletion(ctx, members, configured)
+	default:
+		return fmt.Errorf("%w: %d products belong to it and it configures %d products", domain.ErrProductGroupInUse, len(members), len(configured))
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.productRepository.DeleteProductGroup(ctx, id)
+	if err != nil {
+		s.logger.Error("failed to delete product group", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	s.logger.Info("deleted product group", map[string]interface{}{
+		"product_group_id": id,
+		"products":         len(members),
+		"configured":       len(configured),
+		"cascade":          input.Cascade,
+		"reassign_to":      input.ReassignTo,
+	})
+
+	return nil
+}
+
+// reassignProducts points the references of products to the product group
+// from at the product group to.
+func (s *ProductService) reassignProducts(ctx context.Context, from uuid.UUID, to uuid.UUID, products []domain.Product) error {
+	_, err := s.productRepository.GetProductGroup(ctx, to)
+	if errors.Is(err, domain.ErrNotFound) {
+		validationErr := &domain.ValidationError{}
+		validationErr.Addf("reassign_to", "product group %s does not exist", to)
+		return validationErr
+	}
+	if err != nil {
+		s.logger.Error("failed to get product group by ID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	// A product can be both in the group and configured by it
+	updated := make(map[uuid.UUID]bool, len(products))
+	for _, product := range products {
+		if updated[product.ID] {
+			continue
+		}
+		updated[product.ID] = true
+
+		product.MoveProductGroup(from, to)
+		err = s.productRepository.UpdateProduct(ctx, &product)
+		if err != nil {
+			s.logger.Error("failed to update product", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cascadeProductGroupDeletion deletes the products in a product group and
+// turns the products it configures into plain products.
+func (s *ProductService) cascadeProductGroupDeletion(ctx context.Context, members []domain.Product, configured []domain.Product) error {
+	deleted := make(map[uuid.UUID]bool, len(members))
+	for _, product := range members {
+		err := s.productRepository.DeleteProduct(ctx, product.ID)
+		if err != nil {
+			s.logger.Error("failed to delete product", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		err = s.inventoryRepository.DeleteStockLevel(ctx, product.ID)
+		if err != nil {
+			s.logger.Error("failed to delete stock level", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		deleted[product.ID] = true
+	}
+
+	for _, product := range configured {
+		if deleted[product.ID] {
+			continue
+		}
+
+		product.StopConfiguration()
+		err := s.productRepository.UpdateProduct(ctx, &product)
+		if err != nil {
+			s.logger.Error("failed to update product", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReorderProductGroups rewrites the order of many product groups at once.
+func (s *ProductService) ReorderProductGroups(ctx context.Context, input domain.ReorderProductGroupsInput) (*DTOProductGroupList, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(input.ProductGroups))
+	for i, productGroupOrder := range input.ProductGroups {
+		ids[i] = productGroupOrder.ID
+	}
+
+	var productGroups []domain.ProductGroup
+	err = s.inTransaction(ctx, func(tx *ProductService) error {
+		existing, err := tx.productRepository.GetProductGroupsByIDs(ctx, ids)
+		if err != nil {
+			tx.logger.Error("failed to get product groups by IDs", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		productGroupsByID := make(map[uuid.UUID]domain.ProductGroup, len(existing))
+		for _, productGroup := range existing {
+			productGroupsByID[productGroup.ID] = productGroup
+		}
+
+		validationErr := &domain.ValidationError{}
+		for i, productGroupOrder := range input.ProductGroups {
+			if _, ok := productGroupsByID[productGroupOrder.ID]; !ok {
+				validationErr.Addf(fmt.Sprintf("product_groups[%d].id", i), "product group %s does not exist", productGroupOrder.ID)
+			}
+		}
+		if err := validationErr.ErrOrNil(); err != nil {
+			return err
+		}
+
+		productGroups = make([]domain.ProductGroup, len(input.ProductGroups))
+		for i, productGroupOrder := range input.ProductGroups {
+			productGroup := productGroupsByID[productGroupOrder.ID]
+			productGroup.Order = productGroupOrder.Order
+
+			err = tx.productRepository.UpdateProductGroup(ctx, &productGroup)
+			if err != nil {
+				tx.logger.Error("failed to update product group", map[string]interface{}{
+					"error": err,
+				})
+				return err
+			}
+			productGroups[i] = productGroup
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DTOProductGroupList{ProductGroups: productGroups}, nil
+}
+
+func (s *ProductService) CreateProduct(ctx context.Context, productInput domain.CreateProductInput) (*DTOProductDetails, error) {
+	product, err := domain.CreateProduct(s.idGenerator.NewID(), productInput)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkProductGroupsExist(ctx, product)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.productRepository.CreateProduct(product)
+	err = s.productRepository.CreateProduct(ctx, product)
 	if err != nil {
 		s.logger.Error("failed to create product", map[string]interface{}{
 			"error": err,
@@ -104,8 +418,8 @@ func (s *ProductService) CreateProduct(productInput domain.CreateProductInput) (
 	return &DTOProductDetails{Product: *product}, nil
 }
 
-func (s *ProductService) UpdateProduct(id string, productInput domain.UpdateProductInput) (*DTOProductDetails, error) {
-	uuidId, err := uuid.Parse(id)
+func (s *ProductService) UpdateProduct(ctx context.Context, id string, productInput domain.UpdateProductInput) (*DTOProductDetails, error) {
+	uuidId, err := parseID(id)
 	if err != nil {
 		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
@@ -113,7 +427,7 @@ func (s *ProductService) UpdateProduct(id string, productInput domain.UpdateProd
 		return nil, err
 	}
 
-	product, err := s.productRepository.GetProduct(uuidId)
+	product, err := s.productRepository.GetProduct(ctx, uuidId)
 	if err != nil {
 		s.logger.Error("failed to get product by ID", map[string]interface{}{
 			"error": err,
@@ -126,7 +440,12 @@ func (s *ProductService) UpdateProduct(id string, productInput domain.UpdateProd
 		return nil, err
 	}
 
-	err = s.productRepository.UpdateProduct(product)
+	err = s.checkProductGroupsExist(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.productRepository.UpdateProduct(ctx, product)
 	if err != nil {
 		s.logger.Error("failed to update product", map[string]interface{}{
 			"error": err,
@@ -137,28 +456,73 @@ func (s *ProductService) UpdateProduct(id string, productInput domain.UpdateProd
 	return &DTOProductDetails{Product: *product}, nil
 }
 
-func (s *ProductService) DeleteProduct(id string) error {
-	uuidId, err := uuid.Parse(id)
+// checkProductGroupsExist reports the product group fields of a product that
+// point to groups that don't exist.
+func (s *ProductService) checkProductGroupsExist(ctx context.Context, product *domain.Product) error {
+	validationErr := &domain.ValidationError{}
+
+	checkExists := func(field string, productGroupID uuid.UUID) error {
+		_, err := s.productRepository.GetProductGroup(ctx, productGroupID)
+		if errors.Is(err, domain.ErrNotFound) {
+			validationErr.Addf(field, "product group %s does not exist", productGroupID)
+			return nil
+		}
+		if err != nil {
+			s.logger.Error("failed to get product group by ID", map[string]interface{}{
+				"error": err,
+			})
+		}
+		return err
+	}
+
+	err := checkExists("product_group_id", product.ProductGroupID)
 	if err != nil {
-		s.logger.Error("failed to parse UUID", map[string]interface{}{
-			"error": err,
-		})
 		return err
 	}
 
-	err = s.productRepository.DeleteProduct(uuidId)
+	if product.ConfiguredByProductGroupID != nil {
+		err = checkExists("configured_by_product_group_id", *product.ConfiguredByProductGroupID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return validationErr.ErrOrNil()
+}
+
+func (s *ProductService) DeleteProduct(ctx context.Context, id string) error {
+	uuidId, err := parseID(id)
 	if err != nil {
-		s.logger.Error("failed to delete product", map[string]interface{}{
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
 		})
 		return err
 	}
 
-	return nil
+	// The stock level goes with the product
+	return s.inTransaction(ctx, func(tx *ProductService) error {
+		err := tx.productRepository.DeleteProduct(ctx, uuidId)
+		if err != nil {
+			tx.logger.Error("failed to delete product", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		err = tx.inventoryRepository.DeleteStockLevel(ctx, uuidId)
+		if err != nil {
+			tx.logger.Error("failed to delete stock level", map[string]interface{}{
+				"error": err,
+			})
+			return err
+		}
+
+		return nil
+	})
 }
 
-func (s *ProductService) GetProductByID(id string) (*DTOProductDetails, error) {
-	uuidId, err := uuid.Parse(id)
+func (s *ProductService) GetProductByID(ctx context.Context, id string) (*DTOProductDetails, error) {
+	uuidId, err := parseID(id)
 	if err != nil {
 		s.logger.Error("failed to parse UUID", map[string]interface{}{
 			"error": err,
@@ -166,7 +530,7 @@ func (s *ProductService) GetProductByID(id string) (*DTOProductDetails, error) {
 		return nil, err
 	}
 
-	product, err := s.productRepository.GetProduct(uuidId)
+	product, err := s.productRepository.GetProduct(ctx, uuidId)
 	if err != nil {
 		s.logger.Error("failed to get product by ID", map[string]interface{}{
 			"error": err,
@@ -177,8 +541,13 @@ func (s *ProductService) GetProductByID(id string) (*DTOProductDetails, error) {
 	return &DTOProductDetails{Product: *product}, nil
 }
 
-func (s *ProductService) ListProducts() (*DTOProductList, error) {
-	products, err := s.productRepository.ListProducts()
+func (s *ProductService) ListProducts(ctx context.Context, query domain.ProductQuery) (*DTOProductList, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	products, total, err := s.productRepository.ListProducts(ctx, query)
 	if err != nil {
 		s.logger.Error("failed to list products", map[string]interface{}{
 			"error": err,
@@ -186,23 +555,24 @@ func (s *ProductService) ListProducts() (*DTOProductList, error) {
 		return nil, err
 	}
 
-	return &DTOProductList{Products: products}, nil
+	return &DTOProductList{Products: products, Pagination: newDTOPagination(query.Page, total)}, nil
 }
 
-func (s *ProductService) GetProductsByProductGroupID(id uuid.UUID) (*DTOProductList, error) {
-	products, err := s.productRepository.ListProductsByProductGroupID(id)
+func (s *ProductService) GetProductsByProductGroupID(ctx context.Context, id uuid.UUID, query domain.ProductQuery) (*DTOProductList, error) {
+	_, err := s.productRepository.GetProductGroup(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to list products by product group ID", map[string]interface{}{
+		s.logger.Error("failed to get product group by ID", map[string]interface{}{
 			"error": err,
 		})
 		return nil, err
 	}
 
-	return &DTOProductList{Products: products}, nil
+	query.ProductGroupID = &id
+	return s.ListProducts(ctx, query)
 }
 
-func (s *ProductService) GetProductGroupsWithProducts() (*DTOProductGroupWithProducts, error) {
-	productGroupsWithProducts, err := s.productRepository.ListProductGroupsWithProducts()
+func (s *ProductService) GetProductGroupsWithProducts(ctx context.Context) (*DTOProductGroupWithProducts, error) {
+	productGroupsWithProducts, err := s.productRepository.ListProductGroupsWithProducts(ctx)
 	if err != nil {
 		s.logger.Error("failed to get product groups with products", map[string]interface{}{
 			"error": err,
@@ -210,5 +580,192 @@ func (s *ProductService) GetProductGroupsWithProducts() (*DTOProductGroupWithPro
 		return nil, err
 	}
 
-	return &DTOProductGroupWithProducts{ProductGroups: productGroupsWithProducts}, nil
+	var products []domain.Product
+	for _, productGroupWithProducts := range productGroupsWithProducts {
+		products = append(products, productGroupWithProducts.Products...)
+	}
+
+	availabilities, err := s.computeAvailabilities(ctx, products)
+	if err != nil {
+		return nil, err
+	}
+
+	dtoProductGroups := make([]DTOProductGroupWithAvailableProducts, len(productGroupsWithProducts))
+	for i, productGroupWithProducts := range productGroupsWithProducts {
+		dtoProducts := make([]DTOAvailableProduct, len(productGroupWithProducts.Products))
+		for j, product := range productGroupWithProducts.Products {
+			dtoProducts[j] = DTOAvailableProduct{
+				Product:             product,
+				ProductAvailability: availabilities[product.ID],
+			}
+		}
+
+		dtoProductGroups[i] = DTOProductGroupWithAvailableProducts{
+			ProductGroup: productGroupWithProducts.ProductGroup,
+			Products:     dtoProducts,
+		}
+	}
+
+	return &DTOProductGroupWithProducts{ProductGroups: dtoProductGroups}, nil
+}
+
+// computeAvailabilities works out the availability of each product from the
+// stock that is not reserved by carts. Components of configurable products,
+// stock levels and reservations are loaded in batches.
+func (s *ProductService) computeAvailabilities(ctx context.Context, products []domain.Product) (map[uuid.UUID]domain.ProductAvailability, error) {
+	var configuringGroupIDs []uuid.UUID
+	for _, product := range products {
+		if product.IsConfigurable && product.ConfiguredByProductGroupID != nil {
+			configuringGroupIDs = append(configuringGroupIDs, *product.ConfiguredByProductGroupID)
+		}
+	}
+
+	components, err := s.productRepository.ListProductsByProductGroupIDs(ctx, configuringGroupIDs)
+	if err != nil {
+		s.logger.Error("failed to list component products", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	componentsByGroupID := make(map[uuid.UUID][]domain.Product)
+	productIDs := make([]uuid.UUID, 0, len(products)+len(components))
+	for _, component := range components {
+		componentsByGroupID[component.ProductGroupID] = append(componentsByGroupID[component.ProductGroupID], component)
+		productIDs = append(productIDs, component.ID)
+	}
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+
+	stockLevels, err := s.inventoryRepository.GetStockLevels(ctx, productIDs)
+	if err != nil {
+		s.logger.Error("failed to get stock levels", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	reserved, err := s.inventoryRepository.GetReservedQuantities(ctx, productIDs, s.clock.Now())
+	if err != nil {
+		s.logger.Error("failed to get reserved quantities", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	stock := make(map[uuid.UUID]int, len(stockLevels))
+	for _, stockLevel := range stockLevels {
+		stock[stockLevel.ProductID] = stockLevel.Quantity - reserved[stockLevel.ProductID]
+	}
+
+	availabilities := make(map[uuid.UUID]domain.ProductAvailability, len(products))
+	for _, product := range products {
+		var productComponents []domain.Product
+		if product.ConfiguredByProductGroupID != nil {
+			productComponents = componentsByGroupID[*product.ConfiguredByProductGroupID]
+		}
+		availabilities[product.ID] = domain.ComputeAvailability(product, stock, productComponents)
+	}
+
+	return availabilities, nil
+}
+
+func (s *ProductService) GetProductStock(ctx context.Context, id string) (*DTOProductStock, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	product, err := s.productRepository.GetProduct(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to get product by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return s.buildProductStock(ctx, product)
+}
+
+func (s *ProductService) SetProductStock(ctx context.Context, id string, input domain.SetStockLevelInput) (*DTOProductStock, error) {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	product, err := s.productRepository.GetProduct(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to get product by ID", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	stockLevel, err := domain.CreateStockLevel(product.ID, input, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.inventoryRepository.SetStockLevel(ctx, stockLevel)
+	if err != nil {
+		s.logger.Error("failed to set stock level", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return s.buildProductStock(ctx, product)
+}
+
+func (s *ProductService) DeleteProductStock(ctx context.Context, id string) error {
+	uuidId, err := parseID(id)
+	if err != nil {
+		s.logger.Error("failed to parse UUID", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	err = s.inventoryRepository.DeleteStockLevel(ctx, uuidId)
+	if err != nil {
+		s.logger.Error("failed to delete stock level", map[string]interface{}{
+			"error": err,
+		})
+		return err
+	}
+
+	return nil
+}
+
+func (s *ProductService) buildProductStock(ctx context.Context, product *domain.Product) (*DTOProductStock, error) {
+	stockLevels, err := s.inventoryRepository.GetStockLevels(ctx, []uuid.UUID{product.ID})
+	if err != nil {
+		s.logger.Error("failed to get stock levels", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	var stockLevel *domain.StockLevel
+	if len(stockLevels) > 0 {
+		stockLevel = &stockLevels[0]
+	}
+
+	availabilities, err := s.computeAvailabilities(ctx, []domain.Product{*product})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DTOProductStock{
+		ProductID:    product.ID,
+		StockLevel:   stockLevel,
+		Availability: availabilities[product.ID],
+	}, nil
 }
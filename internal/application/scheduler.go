@@ -0,0 +1,282 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+)
+
+// scheduledJob is a job registered with the scheduler. running keeps runs of
+// the same job from overlapping.
+type scheduledJob struct {
+	name     string
+	schedule domain.Schedule
+	jitter   time.Duration
+	run      func(ctx context.Context) error
+	running  atomic.Bool
+	// nextRun is the time of the next scheduled run, guarded by the mutex of
+	// the scheduler
+	nextRun time.Time
+}
+
+// Scheduler runs named background jobs on their schedules and on demand, and
+// records the outcome of every run.
+type Scheduler struct {
+	jobRunRepository ports.JobRunRepository
+	clock            ports.Clock
+	idGenerator      ports.IDGenerator
+	logger           ports.Logger
+
+	mu         sync.Mutex
+	jobs       []*scheduledJob
+	jobsByName map[string]*scheduledJob
+	// ctx is the context of Run, the runs started by Trigger use it too
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func NewScheduler(jobRunRepository ports.JobRunRepository, clock ports.Clock, idGenerator ports.IDGenerator, logger ports.Logger) *Scheduler {
+	return &Scheduler{
+		jobRunRepository: jobRunRepository,
+		clock:            clock,
+		idGenerator:      idGenerator,
+		logger:           logger,
+		jobsByName:       make(map[string]*scheduledJob),
+	}
+}
+
+type DTOJob struct {
+	Name            string         `json:"name"`
+	Schedule        string         `json:"schedule"`
+	Running         bool           `json:"running"`
+	NextRunDateTime *time.Time     `json:"next_run_date_time"`
+	LastRun         *domain.JobRun `json:"last_run"`
+}
+
+type DTOJobList struct {
+	Jobs []DTOJob `json:"jobs"`
+}
+
+type DTOJobRunList struct {
+	Runs       []*domain.JobRun `json:"runs"`
+	Pagination *DTOPagination   `json:"pagination"`
+}
+
+// Register adds a job. Every run is delayed by a random duration up to
+// jitter, so that jobs on the same schedule don't all start at once. Jobs
+// are registered before Run is called.
+func (s *Scheduler) Register(name string, schedule domain.Schedule, jitter time.Duration, run func(ctx context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobsByName[name]; ok {
+		return domain.Errorf(domain.ErrConflict, "job %q is already registered", name)
+	}
+	if jitter < 0 {
+		return domain.Errorf(domain.ErrInvalidInput, "jitter of job %q cannot be negative", name)
+	}
+
+	job := &scheduledJob{name: name, schedule: schedule, jitter: jitter, run: run}
+	s.jobs = append(s.jobs, job)
+	s.jobsByName[name] = job
+	return nil
+}
+
+// Run runs the jobs on their schedules until ctx is cancelled, then waits for
+// the runs in progress to return.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.runSchedule(ctx, job)
+		}()
+	}
+
+	<-ctx.Done()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) runSchedule(ctx context.Context, job *scheduledJob) {
+	for {
+		next := job.schedule.Next(s.clock.Now())
+		if job.jitter > 0 {
+			next = next.Add(rand.N(job.jitter))
+		}
+		s.mu.Lock()
+		job.nextRun = next
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(next.Sub(s.clock.Now())):
+		}
+
+		if !job.running.CompareAndSwap(false, true) {
+			run := domain.StartJobRun(s.idGenerator.NewID(), job.name, domain.JobTriggerSchedule, s.clock.Now())
+			run.Skip("the previous run was still going")
+			s.logger.Warn("skipped job run, the previous run is still going", map[string]interface{}{
+				"job": job.name,
+			})
+			s.recordRun(ctx, run)
+			continue
+		}
+		s.runJob(ctx, job, domain.JobTriggerSchedule)
+	}
+}
+
+// runJob runs a job that has been marked as running and records the run.
+func (s *Scheduler) runJob(ctx context.Context, job *scheduledJob, trigger domain.JobTrigger) {
+	defer job.running.Store(false)
+
+	run := domain.StartJobRun(s.idGenerator.NewID(), job.name, trigger, s.clock.Now())
+	s.logger.Info("starting job", map[string]interface{}{
+		"job":     job.name,
+		"trigger": trigger,
+	})
+
+	run.Finish(s.call(ctx, job), s.clock.Now())
+	if run.Status == domain.JobRunStatusFailed {
+		s.logger.Error("job failed", map[string]interface{}{
+			"job":   job.name,
+			"error": run.Error,
+		})
+	} else {
+		s.logger.Info("job finished", map[string]interface{}{
+			"job":         job.name,
+			"duration_ms": run.DurationMs,
+		})
+	}
+
+	s.recordRun(ctx, run)
+}
+
+// call runs the job, turning a panic into a failed run.
+func (s *Scheduler) call(ctx context.Context, job *scheduledJob) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return job.run(ctx)
+}
+
+// recordRun records the run even when it was cut short by a shutdown.
+func (s *Scheduler) recordRun(ctx context.Context, run *domain.JobRun) {
+	err := s.jobRunRepository.CreateJobRun(context.WithoutCancel(ctx), run)
+	if err != nil {
+		s.logger.Error("failed to record job run", map[string]interface{}{
+			"error": err,
+			"job":   run.JobName,
+		})
+	}
+}
+
+// Trigger starts a run of the job right away. It does not wait for the run to
+// finish.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*DTOJob, error) {
+	s.mu.Lock()
+	job, ok := s.jobsByName[name]
+	runCtx := s.ctx
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, domain.ErrJobNotFound
+	}
+	if runCtx == nil || runCtx.Err() != nil {
+		return nil, domain.NewError(domain.ErrConflict, "the scheduler is not running")
+	}
+	if !job.running.CompareAndSwap(false, true) {
+		return nil, domain.ErrJobAlreadyRunning
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runJob(runCtx, job, domain.JobTriggerManual)
+	}()
+
+	return s.buildJob(ctx, job)
+}
+
+func (s *Scheduler) ListJobs(ctx context.Context) (*DTOJobList, error) {
+	s.mu.Lock()
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	dtoJobs := make([]DTOJob, 0, len(jobs))
+	for _, job := range jobs {
+		dtoJob, err := s.buildJob(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		dtoJobs = append(dtoJobs, *dtoJob)
+	}
+
+	return &DTOJobList{Jobs: dtoJobs}, nil
+}
+
+func (s *Scheduler) GetJobRuns(ctx context.Context, name string, page domain.Page) (*DTOJobRunList, error) {
+	s.mu.Lock()
+	_, ok := s.jobsByName[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, domain.ErrJobNotFound
+	}
+
+	page, err := page.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	runs, total, err := s.jobRunRepository.ListJobRuns(ctx, name, page)
+	if err != nil {
+		s.logger.Error("failed to list job runs", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOJobRunList{Runs: runs, Pagination: newDTOPagination(page, total)}, nil
+}
+
+// buildJob describes a job with its latest run.
+func (s *Scheduler) buildJob(ctx context.Context, job *scheduledJob) (*DTOJob, error) {
+	runs, _, err := s.jobRunRepository.ListJobRuns(ctx, job.name, domain.Page{Limit: 1})
+	if err != nil {
+		s.logger.Error("failed to list job runs", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	dtoJob := &DTOJob{
+		Name:     job.name,
+		Schedule: job.schedule.String(),
+		Running:  job.running.Load(),
+	}
+	s.mu.Lock()
+	if !job.nextRun.IsZero() {
+		nextRun := job.nextRun
+		dtoJob.NextRunDateTime = &nextRun
+	}
+	s.mu.Unlock()
+	if len(runs) > 0 {
+		dtoJob.LastRun = runs[0]
+	}
+
+	return dtoJob, nil
+}
@@ -0,0 +1,133 @@
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
+	"github.com/morgansundqvist/service-composable-commerce/internal/application"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// blockingJob is a job whose runs last until release is closed.
+type blockingJob struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingJob() *blockingJob {
+	return &blockingJob{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (j *blockingJob) run(ctx context.Context) error {
+	select {
+	case j.started <- struct{}{}:
+	default:
+	}
+	<-j.release
+	return nil
+}
+
+// startScheduler runs a scheduler with the job until the test ends, returning
+// the scheduler and the clock it runs on.
+func startScheduler(t *testing.T, schedule domain.Schedule, job *blockingJob) (*application.Scheduler, *adapters.FakeClock) {
+	t.Helper()
+
+	test := newOrderTest(t)
+	scheduler := application.NewScheduler(test.jobRunRepository, test.clock, test.idGenerator, test.logger)
+	if err := scheduler.Register("slow", schedule, 0, job.run); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return scheduler, test.clock
+}
+
+// trigger starts a run of the job, waiting for the scheduler to start.
+func trigger(t *testing.T, scheduler *application.Scheduler, job *blockingJob) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := scheduler.Trigger(context.Background(), "slow")
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("trigger: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	<-job.started
+}
+
+// waitForRun waits for a run of the job with the given trigger and status to
+// be recorded, calling tick between the checks when it is not nil.
+func waitForRun(t *testing.T, scheduler *application.Scheduler, trigger domain.JobTrigger, status domain.JobRunStatus, tick func()) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, err := scheduler.GetJobRuns(context.Background(), "slow", domain.Page{Limit: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, run := range runs.Runs {
+			if run.Trigger == trigger && run.Status == status {
+				return
+			}
+		}
+		if tick != nil {
+			tick()
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no %s run with status %s was recorded", trigger, status)
+}
+
+func TestTriggerDuringRunIsRejected(t *testing.T) {
+	job := newBlockingJob()
+	schedule, err := domain.ParseCron("0 0 1 1 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler, _ := startScheduler(t, schedule, job)
+
+	trigger(t, scheduler, job)
+
+	if _, err := scheduler.Trigger(context.Background(), "slow"); !errors.Is(err, domain.ErrJobAlreadyRunning) {
+		t.Fatalf("trigger during run: error = %v, want %v", err, domain.ErrJobAlreadyRunning)
+	}
+
+	close(job.release)
+	waitForRun(t, scheduler, domain.JobTriggerManual, domain.JobRunStatusSucceeded, nil)
+
+	// Once the run is over the job can be started again
+	trigger(t, scheduler, job)
+}
+
+func TestScheduledRunDuringRunIsSkipped(t *testing.T) {
+	job := newBlockingJob()
+	interval := time.Hour
+	scheduler, clock := startScheduler(t, domain.IntervalSchedule{Interval: interval}, job)
+
+	trigger(t, scheduler, job)
+	waitForRun(t, scheduler, domain.JobTriggerSchedule, domain.JobRunStatusSkipped, func() {
+		clock.Advance(interval)
+	})
+
+	close(job.release)
+	waitForRun(t, scheduler, domain.JobTriggerManual, domain.JobRunStatusSucceeded, nil)
+}
@@ -0,0 +1,73 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+)
+
+// SessionService hands out the tokens that prove a client owns a storefront
+// session. Whoever created the cart of a session gets its token, and only
+// requests that carry it may read or change the cart and its order.
+type SessionService struct {
+	tokenSigner ports.TokenSigner
+	tokenTTL    time.Duration
+	clock       ports.Clock
+	logger      ports.Logger
+}
+
+func NewSessionService(tokenSigner ports.TokenSigner, tokenTTL time.Duration, clock ports.Clock, logger ports.Logger) *SessionService {
+	return &SessionService{
+		tokenSigner: tokenSigner,
+		tokenTTL:    tokenTTL,
+		clock:       clock,
+		logger:      logger,
+	}
+}
+
+type DTOSessionToken struct {
+	Token           string    `json:"token"`
+	ExpiresDateTime time.Time `json:"expires_date_time"`
+}
+
+// sessionSubject keeps session tokens apart from other signed tokens.
+func sessionSubject(sessionId uuid.UUID) string {
+	return "session:" + sessionId.String()
+}
+
+func (s *SessionService) IssueToken(ctx context.Context, sessionId uuid.UUID) (*DTOSessionToken, error) {
+	expires := s.clock.Now().Add(s.tokenTTL)
+	token, err := s.tokenSigner.Sign(sessionSubject(sessionId), expires)
+	if err != nil {
+		s.logger.Error("failed to sign session token", map[string]interface{}{
+			"error": err,
+		})
+		return nil, err
+	}
+
+	return &DTOSessionToken{Token: token, ExpiresDateTime: expires}, nil
+}
+
+// VerifyToken checks that token was issued for the session.
+func (s *SessionService) VerifyToken(ctx context.Context, sessionId string, token string) error {
+	uuidSessionId, err := uuid.Parse(sessionId)
+	if err != nil {
+		return domain.Errorf(domain.ErrInvalidInput, "invalid session ID %q", sessionId)
+	}
+	if token == "" {
+		return domain.ErrMissingSessionToken
+	}
+
+	subject, err := s.tokenSigner.Verify(token)
+	if err != nil {
+		return err
+	}
+	if subject != sessionSubject(uuidSessionId) {
+		return domain.ErrForeignSessionToken
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+// Package config loads the runtime configuration of the API server. Every
+// setting has a default, which a JSON file can override and environment
+// variables can override in turn, so that the same binary can run in every
+// environment.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+// EnvPrefix starts the names of the environment variables read by Load.
+const EnvPrefix = "COMMERCE_"
+
+// Environments the server runs in. Test doubles such as the fake payment
+// gateway are only allowed in development.
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+)
+
+// PaymentProviderFake is the in-process payment gateway for development.
+const PaymentProviderFake = "fake"
+
+// Duration is a time.Duration written as in "5m" or "1h30m" in the config
+// file and environment.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+type Config struct {
+	// Environment is development or production
+	Environment string `json:"environment"`
+	// DatabasePath is the SQLite database file
+	DatabasePath string `json:"database_path"`
+	// Address is what the HTTP server listens on, as in ":3000"
+	Address string `json:"address"`
+	// LogLevel is one of debug, info, warn, error and fatal
+	LogLevel string `json:"log_level"`
+	// ViewsDir holds the HTML templates of the storefront
+	ViewsDir       string   `json:"views_dir"`
+	RequestTimeout Duration `json:"request_timeout"`
+	// ShutdownTimeout is how long requests in flight and the background jobs
+	// get to finish when the server is stopped
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	// CartCleanupSchedule is when abandoned carts are deleted and
+	// ReservationExpirySchedule when expired reservations are released, both
+	// as "@every <duration>" or as a cron expression
+	CartCleanupSchedule       string `json:"cart_cleanup_schedule"`
+	ReservationExpirySchedule string `json:"reservation_expiry_schedule"`
+	// JobJitter is the most a scheduled job run is delayed by at random
+	JobJitter Duration `json:"job_jitter"`
+	// AbandonedCartAge is how long a cart can go unchanged before it is deleted
+	AbandonedCartAge Duration `json:"abandoned_cart_age"`
+	ReservationTTL   Duration `json:"reservation_ttl"`
+	// VATRatePercent is the VAT included in all prices
+	VATRatePercent int               `json:"vat_rate_percent"`
+	Discounts      []domain.Discount `json:"discounts"`
+	// SessionSecret signs session and customer tokens. Without it a random
+	// secret is used, and tokens stop working when the server restarts.
+	SessionSecret    string   `json:"session_secret"`
+	SessionTokenTTL  Duration `json:"session_token_ttl"`
+	CustomerTokenTTL Duration `json:"customer_token_ttl"`
+	// PaymentProvider is the payment gateway orders are paid through
+	PaymentProvider string `json:"payment_provider"`
+	// PaymentCallbackSecret signs the callbacks of the fake payment gateway.
+	// Without it a random secret is used.
+	PaymentCallbackSecret string `json:"payment_callback_secret"`
+}
+
+// Default returns the configuration used for everything that is not
+// configured. It is a development setup, production has to be chosen
+// explicitly together with a real payment provider.
+func Default() *Config {
+	return &Config{
+		Environment:               EnvironmentDevelopment,
+		DatabasePath:              "commerce.db",
+		Address:                   ":3000",
+		LogLevel:                  "debug",
+		ViewsDir:                  "./views",
+		RequestTimeout:            Duration(30 * time.Second),
+		ShutdownTimeout:           Duration(15 * time.Second),
+		CartCleanupSchedule:       "@every 5m",
+		ReservationExpirySchedule: "@every 1m",
+		JobJitter:                 Duration(10 * time.Second),
+		AbandonedCartAge:          Duration(10 * time.Minute),
+		ReservationTTL:            Duration(10 * time.Minute),
+		// 12% is the Swedish VAT rate for food
+		VATRatePercent:   12,
+		SessionTokenTTL:  Duration(24 * time.Hour),
+		CustomerTokenTTL: Duration(7 * 24 * time.Hour),
+		PaymentProvider:  PaymentProviderFake,
+	}
+}
+
+// Load returns the default configuration overridden by the JSON file at path,
+// if path is not empty, and by the environment. Unknown keys in the file are
+// rejected, so that a misspelled setting doesn't quietly keep its default.
+// The result is validated.
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return nil, errors.Join(err, config.Validate())
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// loadEnv overrides the settings that have an environment variable set.
+func (c *Config) loadEnv() error {
+	var errs []error
+	str := func(name string, target *string) {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			*target = value
+		}
+	}
+	duration := func(name string, target *Duration) {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+				return
+			}
+			*target = Duration(parsed)
+		}
+	}
+	integer := func(name string, target *int) {
+		if value, ok := os.LookupEnv(EnvPrefix + name); ok {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	str("ENVIRONMENT", &c.Environment)
+	str("DATABASE_PATH", &c.DatabasePath)
+	str("ADDRESS", &c.Address)
+	str("LOG_LEVEL", &c.LogLevel)
+	str("VIEWS_DIR", &c.ViewsDir)
+	duration("REQUEST_TIMEOUT", &c.RequestTimeout)
+	duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+	str("CART_CLEANUP_SCHEDULE", &c.CartCleanupSchedule)
+	str("RESERVATION_EXPIRY_SCHEDULE", &c.ReservationExpirySchedule)
+	duration("JOB_JITTER", &c.JobJitter)
+	duration("ABANDONED_CART_AGE", &c.AbandonedCartAge)
+	duration("RESERVATION_TTL", &c.ReservationTTL)
+	integer("VAT_RATE_PERCENT", &c.VATRatePercent)
+	str("SESSION_SECRET", &c.SessionSecret)
+	duration("SESSION_TOKEN_TTL", &c.SessionTokenTTL)
+	duration("CUSTOMER_TOKEN_TTL", &c.CustomerTokenTTL)
+	str("PAYMENT_PROVIDER", &c.PaymentProvider)
+	str("PAYMENT_CALLBACK_SECRET", &c.PaymentCallbackSecret)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Environment {
+	case EnvironmentDevelopment, EnvironmentProduction:
+	default:
+		errs = append(errs, fmt.Errorf("environment %q is not one of development and production", c.Environment))
+	}
+	if c.DatabasePath == "" {
+		errs = append(errs, errors.New("database_path is required"))
+	}
+	if c.Address == "" {
+		errs = append(errs, errors.New("address is required"))
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error", "fatal":
+	default:
+		errs = append(errs, fmt.Errorf("log_level %q is not one of debug, info, warn, error and fatal", c.LogLevel))
+	}
+	if c.ViewsDir == "" {
+		errs = append(errs, errors.New("views_dir is required"))
+	}
+
+	durations := []struct {
+		name  string
+		value Duration
+	}{
+		{"request_timeout", c.RequestTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"abandoned_cart_age", c.AbandonedCartAge},
+		{"reservation_ttl", c.ReservationTTL},
+		{"session_token_ttl", c.SessionTokenTTL},
+		{"customer_token_ttl", c.CustomerTokenTTL},
+	}
+	for _, duration := range durations {
+		if duration.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", duration.name))
+		}
+	}
+
+	if _, err := domain.ParseSchedule(c.CartCleanupSchedule); err != nil {
+		errs = append(errs, fmt.Errorf("cart_cleanup_schedule: %w", err))
+	}
+	if _, err := domain.ParseSchedule(c.ReservationExpirySchedule); err != nil {
+		errs = append(errs, fmt.Errorf("reservation_expiry_schedule: %w", err))
+	}
+	if c.JobJitter < 0 {
+		errs = append(errs, errors.New("job_jitter cannot be negative"))
+	}
+
+	if c.VATRatePercent < 0 || c.VATRatePercent > 100 {
+		errs = append(errs, errors.New("vat_rate_percent must be between 0 and 100"))
+	}
+	if c.SessionSecret != "" && len(c.SessionSecret) < 32 {
+		errs = append(errs, errors.New("session_secret must be at least 32 characters"))
+	}
+
+	switch c.PaymentProvider {
+	case PaymentProviderFake:
+		if c.Environment != EnvironmentDevelopment {
+			errs = append(errs, errors.New("payment_provider fake takes payments without charging anyone and is only allowed in the development environment"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("payment_provider %q is not one of %s", c.PaymentProvider, PaymentProviderFake))
+	}
+	if c.PaymentCallbackSecret != "" && len(c.PaymentCallbackSecret) < 32 {
+		errs = append(errs, errors.New("payment_callback_secret must be at least 32 characters"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		wantErr string
+		check   func(t *testing.T, config *Config)
+	}{
+		{
+			name: "file overrides defaults",
+			file: `{"environment": "development", "address": ":8080"}`,
+			check: func(t *testing.T, config *Config) {
+				if config.Address != ":8080" {
+					t.Errorf("address = %q, want :8080", config.Address)
+				}
+			},
+		},
+		{
+			name: "environment overrides file",
+			file: `{"environment": "development", "address": ":8080"}`,
+			env:  map[string]string{"ADDRESS": ":9090"},
+			check: func(t *testing.T, config *Config) {
+				if config.Address != ":9090" {
+					t.Errorf("address = %q, want :9090", config.Address)
+				}
+			},
+		},
+		{
+			name:    "misspelled key",
+			file:    `{"environment": "development", "adress": ":8080"}`,
+			wantErr: `unknown field "adress"`,
+		},
+		{
+			name: "defaults",
+			file: `{}`,
+			check: func(t *testing.T, config *Config) {
+				if config.Environment != EnvironmentDevelopment || config.PaymentProvider != PaymentProviderFake {
+					t.Errorf("environment and payment provider = %q and %q, want development and fake", config.Environment, config.PaymentProvider)
+				}
+			},
+		},
+		{
+			name:    "fake payment provider in production",
+			file:    `{"environment": "production", "payment_provider": "fake"}`,
+			wantErr: "only allowed in the development environment",
+		},
+		{
+			name:    "unknown payment provider",
+			file:    `{"environment": "development", "payment_provider": "paypal"}`,
+			wantErr: `payment_provider "paypal"`,
+		},
+		{
+			name:    "invalid environment variable and setting are both reported",
+			file:    `{"environment": "development", "log_level": "loud"}`,
+			env:     map[string]string{"REQUEST_TIMEOUT": "soon"},
+			wantErr: "log_level",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(EnvPrefix+name, value)
+			}
+
+			config, err := Load(writeConfigFile(t, tt.file))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, config)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key so that leaked keys are easy to spot.
+const APIKeyPrefix = "cck_"
+
+var (
+	ErrAPIKeyNotFound = NewError(ErrNotFound, "API key not found")
+	ErrInvalidAPIKey  = NewError(ErrUnauthenticated, "invalid API key")
+	ErrMissingAPIKey  = NewError(ErrUnauthenticated, "an API key is required")
+
+	ErrInvalidToken        = NewError(ErrUnauthenticated, "invalid or expired token")
+	ErrMissingSessionToken = NewError(ErrUnauthenticated, "a session token is required")
+	ErrForeignSessionToken = NewError(ErrForbidden, "session token belongs to another session")
+)
+
+// Role grants access to a part of the admin API.
+type Role string
+
+const (
+	// RoleAdmin may do everything the other roles may
+	RoleAdmin         Role = "admin"
+	RoleCatalogEditor Role = "catalog_editor"
+	RoleFulfilment    Role = "fulfilment"
+)
+
+var Roles = []Role{RoleAdmin, RoleCatalogEditor, RoleFulfilment}
+
+func (r Role) IsValid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a credential of a member of staff or an integration. Only the
+// hash of the key is stored, the key itself is shown once when it is created.
+type APIKey struct {
+	ID              uuid.UUID `json:"id"`
+	Name            string    `json:"name"`
+	KeyHash         string    `json:"-"`
+	Roles           []Role    `json:"roles"`
+	CreatedDateTime time.Time `json:"created_date_time"`
+}
+
+type CreateAPIKeyInput struct {
+	Name  string `json:"name"`
+	Roles []Role `json:"roles"`
+}
+
+// CreateAPIKey creates an API key with a new random key, which is returned
+// next to it.
+func CreateAPIKey(id uuid.UUID, input CreateAPIKeyInput, now time.Time) (*APIKey, string, error) {
+	validationErr := &ValidationError{}
+	if strings.TrimSpace(input.Name) == "" {
+		validationErr.Add("name", "name is required")
+	}
+	if len(input.Roles) == 0 {
+		validationErr.Add("roles", "at least one role is required")
+	}
+	for i, role := range input.Roles {
+		if !role.IsValid() {
+			validationErr.Addf(fmt.Sprintf("roles[%d]", i), "unknown role %q", role)
+		}
+	}
+	if err := validationErr.ErrOrNil(); err != nil {
+		return nil, "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := &APIKey{
+		ID:              id,
+		Name:            strings.TrimSpace(input.Name),
+		KeyHash:         HashAPIKey(key),
+		Roles:           input.Roles,
+		CreatedDateTime: now,
+	}
+
+	return apiKey, key, nil
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by. The keys
+// are long and random, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Principal is who a request is made by.
+type Principal struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Roles []Role    `json:"roles"`
+}
+
+func (k *APIKey) Principal() *Principal {
+	return &Principal{
+		ID:    k.ID,
+		Name:  k.Name,
+		Roles: k.Roles,
+	}
+}
+
+// HasAnyRole tells whether the principal has one of roles. Admins have every
+// role.
+func (p *Principal) HasAnyRole(roles ...Role) bool {
+	for _, held := range p.Roles {
+		if held == RoleAdmin {
+			return true
+		}
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passwords are hashed with bcrypt, which only uses the first 72 bytes.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var (
+	ErrCustomerNotFound     = NewError(ErrNotFound, "customer not found")
+	ErrAddressNotFound      = NewError(ErrNotFound, "address not found")
+	ErrEmailTaken           = NewError(ErrConflict, "a customer with this email already exists")
+	ErrInvalidCredentials   = NewError(ErrUnauthenticated, "invalid email or password")
+	ErrMissingCustomerToken = NewError(ErrUnauthenticated, "a customer token is required")
+	ErrCartOfOtherCustomer  = NewError(ErrConflict, "session cart belongs to another customer")
+)
+
+// Customer is a registered shopper. Orders of a customer keep their own copy
+// of the delivery details, so changing the customer does not change them.
+type Customer struct {
+	ID              uuid.UUID `json:"id"`
+	Email           string    `json:"email"`
+	Name            string    `json:"name"`
+	PasswordHash    string    `json:"-"`
+	CreatedDateTime time.Time `json:"created_date_time"`
+}
+
+type RegisterCustomerInput struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+func (input RegisterCustomerInput) Validate() error {
+	validationErr := &ValidationError{}
+	if email := NormalizeEmail(input.Email); email == "" {
+		validationErr.Add("email", "email is required")
+	} else if !strings.Contains(email, "@") {
+		validationErr.Add("email", "email is not a valid email address")
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		validationErr.Add("name", "name is required")
+	}
+	if len(input.Password) < MinPasswordLength {
+		validationErr.Addf("password", "password must be at least %d characters", MinPasswordLength)
+	}
+	if len(input.Password) > MaxPasswordLength {
+		validationErr.Addf("password", "password cannot be longer than %d bytes", MaxPasswordLength)
+	}
+
+	return validationErr.ErrOrNil()
+}
+
+type LoginCustomerInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// NormalizeEmail returns the form emails of customers are stored and looked
+// up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func CreateCustomer(id uuid.UUID, input RegisterCustomerInput, passwordHash string, now time.Time) (*Customer, error) {
+	err := input.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	customer := &Customer{
+		ID:              id,
+		Email:           NormalizeEmail(input.Email),
+		Name:            strings.TrimSpace(input.Name),
+		PasswordHash:    passwordHash,
+		CreatedDateTime: now,
+	}
+
+	return customer, nil
+}
+
+// Address is a delivery address saved by a customer.
+type Address struct {
+	ID              uuid.UUID `json:"id"`
+	CustomerID      uuid.UUID `json:"customer_id"`
+	Name            string    `json:"name"`
+	Address         string    `json:"address"`
+	ZipCode         string    `json:"zip_code"`
+	City            string    `json:"city"`
+	CompanyName     string    `json:"company_name"`
+	CreatedDateTime time.Time `json:"created_date_time"`
+}
+
+type CreateAddressInput struct {
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	ZipCode     string `json:"zip_code"`
+	City        string `json:"city"`
+	CompanyName string `json:"company_name"`
+}
+
+type UpdateAddressInput struct {
+	Name        *string `json:"name"`
+	Address     *string `json:"address"`
+	ZipCode     *string `json:"zip_code"`
+	City        *string `json:"city"`
+	CompanyName *string `json:"company_name"`
+}
+
+func CreateAddress(id uuid.UUID, customerID uuid.UUID, input CreateAddressInput, now time.Time) (*Address, error) {
+	address := Address{
+		ID:              id,
+		CustomerID:      customerID,
+		Name:            input.Name,
+		Address:         input.Address,
+		ZipCode:         input.ZipCode,
+		City:            input.City,
+		CompanyName:     input.CompanyName,
+		CreatedDateTime: now,
+	}
+
+	err := address.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &address, nil
+}
+
+func (a *Address) validate() error {
+	validationErr := &ValidationError{}
+	if strings.TrimSpace(a.Name) == "" {
+		validationErr.Add("name", "name is required")
+	}
+	if strings.TrimSpace(a.Address) == "" {
+		validationErr.Add("address", "address is required")
+	}
+	if strings.TrimSpace(a.ZipCode) == "" {
+		validationErr.Add("zip_code", "zip code is required")
+	}
+	if strings.TrimSpace(a.City) == "" {
+		validationErr.Add("city", "city is required")
+	}
+
+	return validationErr.ErrOrNil()
+}
+
+func (a *Address) Update(input UpdateAddressInput) error {
+	updated := *a
+	if input.Name != nil {
+		updated.Name = *input.Name
+	}
+	if input.Address != nil {
+		updated.Address = *input.Address
+	}
+	if input.ZipCode != nil {
+		updated.ZipCode = *input.ZipCode
+	}
+	if input.City != nil {
+		updated.City = *input.City
+	}
+	if input.CompanyName != nil {
+		updated.CompanyName = *input.CompanyName
+	}
+
+	err := updated.validate()
+	if err != nil {
+		return err
+	}
+
+	*a = updated
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors. Every error the domain, the repositories and the services
+// return on purpose matches one of them with errors.Is, so that callers can
+// react to the kind without looking at the message.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidInput    = errors.New("invalid input")
+	ErrValidation      = errors.New("validation failed")
+	ErrConflict        = errors.New("conflict")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+var (
+	ErrOrderNotFound        = NewError(ErrNotFound, "order not found")
+	ErrOrderLineNotFound    = NewError(ErrNotFound, "order line not found")
+	ErrProductNotFound      = NewError(ErrNotFound, "product not found")
+	ErrProductGroupNotFound = NewError(ErrNotFound, "product group not found")
+	ErrPaymentNotFound      = NewError(ErrNotFound, "payment not found")
+)
+
+// kindError is an error of a kind. It matches both the kind and the errors it
+// wraps.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// NewError returns an error of the given kind with message.
+func NewError(kind error, message string) error {
+	return &kindError{kind: kind, err: errors.New(message)}
+}
+
+// Errorf returns an error of the given kind formatted as with fmt.Errorf.
+func Errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
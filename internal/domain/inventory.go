@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInsufficientStock = NewError(ErrConflict, "insufficient stock")
+
+// StockLevel is the number of units of a product on hand. Products without a
+// stock level are not tracked and never run out.
+type StockLevel struct {
+	ProductID       uuid.UUID `json:"product_id"`
+	Quantity        int       `json:"quantity"`
+	UpdatedDateTime time.Time `json:"updated_date_time"`
+}
+
+type SetStockLevelInput struct {
+	Quantity int `json:"quantity"`
+}
+
+func CreateStockLevel(productID uuid.UUID, input SetStockLevelInput, now time.Time) (*StockLevel, error) {
+	if input.Quantity < 0 {
+		validationErr := &ValidationError{}
+		validationErr.Add("quantity", "stock quantity cannot be negative")
+		return nil, validationErr
+	}
+
+	stockLevel := &StockLevel{
+		ProductID:       productID,
+		Quantity:        input.Quantity,
+		UpdatedDateTime: now,
+	}
+
+	return stockLevel, nil
+}
+
+// InsufficientStockError reports the product that could not be taken from stock.
+func InsufficientStockError(productID uuid.UUID, requested int, available int) error {
+	return fmt.Errorf("%w: product %s has %d available, %d requested", ErrInsufficientStock, productID, available, requested)
+}
+
+// StockRequirements sums the units of every product an order consumes. Each
+// content line is consumed once per unit of its order line.
+func StockRequirements(orderLines []*OrderLine, contentLinesByOrderLineID map[uuid.UUID][]*OrderLineContentLine) map[uuid.UUID]int {
+	requirements := make(map[uuid.UUID]int)
+	for _, orderLine := range orderLines {
+		requirements[orderLine.ProductID] += orderLine.Quantity
+		for _, contentLine := range contentLinesByOrderLineID[orderLine.ID] {
+			requirements[contentLine.ProductID] += contentLine.Quantity * orderLine.Quantity
+		}
+	}
+	return requirements
+}
+
+// StockReservation holds stock for an order line of a cart until it expires,
+// so that the same units cannot be sold to someone else in the meantime.
+type StockReservation struct {
+	ID              uuid.UUID `json:"id"`
+	OrderID         uuid.UUID `json:"order_id"`
+	OrderLineID     uuid.UUID `json:"order_line_id"`
+	ProductID       uuid.UUID `json:"product_id"`
+	Quantity        int       `json:"quantity"`
+	ExpiresDateTime time.Time `json:"expires_date_time"`
+}
+
+// CreateStockReservations creates one reservation per product used by the
+// order line, including its content lines. newID is called for the ID of
+// each reservation.
+func CreateStockReservations(newID func() uuid.UUID, orderLine *OrderLine, contentLines []*OrderLineContentLine, expires time.Time) []*StockReservation {
+	requirements := StockRequirements(
+		[]*OrderLine{orderLine},
+		map[uuid.UUID][]*OrderLineContentLine{orderLine.ID: contentLines},
+	)
+
+	reservations := make([]*StockReservation, 0, len(requirements))
+	for productID, quantity := range requirements {
+		reservations = append(reservations, &StockReservation{
+			ID:              newID(),
+			OrderID:         orderLine.OrderID,
+			OrderLineID:     orderLine.ID,
+			ProductID:       productID,
+			Quantity:        quantity,
+			ExpiresDateTime: expires,
+		})
+	}
+
+	return reservations
+}
+
+// ProductAvailability tells whether a product can be sold. AvailableQuantity
+// is nil when the product and its components are not stock tracked.
+type ProductAvailability struct {
+	InStock           bool `json:"in_stock"`
+	AvailableQuantity *int `json:"available_quantity"`
+}
+
+// ComputeAvailability works out how many units of a product can be sold from
+// the available stock. stock only contains tracked products. A configurable
+// product is limited by its own stock and by how many full compositions the
+// components of its configuring product group can fill.
+func ComputeAvailability(product Product, stock map[uuid.UUID]int, components []Product) ProductAvailability {
+	var available *int
+	limit := func(quantity int) {
+		if available == nil || quantity < *available {
+			available = &quantity
+		}
+	}
+
+	if quantity, ok := stock[product.ID]; ok {
+		limit(quantity)
+	}
+
+	if product.IsConfigurable && product.ConfiguredQuantity > 0 {
+		componentUnits := 0
+		tracked := true
+		for _, component := range components {
+			if component.IsConfigurable {
+				continue
+			}
+			quantity, ok := stock[component.ID]
+			if !ok {
+				tracked = false
+				break
+			}
+			componentUnits += quantity
+		}
+		if tracked {
+			limit(componentUnits / product.ConfiguredQuantity)
+		}
+	}
+
+	if available != nil && *available < 0 {
+		zero := 0
+		available = &zero
+	}
+
+	return ProductAvailability{
+		InStock:           available == nil || *available > 0,
+		AvailableQuantity: available,
+	}
+}
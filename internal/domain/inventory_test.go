@@ -0,0 +1,82 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/adapters"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+func TestCreateStockReservations(t *testing.T) {
+	product := uuid.MustParse("00000000-0000-0000-0000-0000000000a1")
+	component := uuid.MustParse("00000000-0000-0000-0000-0000000000a2")
+
+	tests := []struct {
+		name         string
+		quantity     int
+		contentLines []*domain.OrderLineContentLine
+		want         map[uuid.UUID]int
+	}{
+		{name: "product on its own", quantity: 2, want: map[uuid.UUID]int{product: 2}},
+		{
+			name:         "content lines per unit",
+			quantity:     2,
+			contentLines: []*domain.OrderLineContentLine{{ProductID: component, Quantity: 3}},
+			want:         map[uuid.UUID]int{product: 2, component: 6},
+		},
+		{
+			name:     "content lines of the same product are summed",
+			quantity: 2,
+			contentLines: []*domain.OrderLineContentLine{
+				{ProductID: component, Quantity: 1},
+				{ProductID: component, Quantity: 2},
+				{ProductID: product, Quantity: 1},
+			},
+			want: map[uuid.UUID]int{product: 4, component: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := adapters.NewFakeClock(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC))
+			idGenerator := adapters.NewSequentialIDGenerator()
+			orderLine := &domain.OrderLine{ID: idGenerator.NewID(), OrderID: idGenerator.NewID(), ProductID: product, Quantity: tt.quantity}
+			expires := clock.Now().Add(10 * time.Minute)
+
+			reservations := domain.CreateStockReservations(idGenerator.NewID, orderLine, tt.contentLines, expires)
+
+			got := make(map[uuid.UUID]int)
+			ids := make(map[uuid.UUID]bool)
+			for _, reservation := range reservations {
+				got[reservation.ProductID] += reservation.Quantity
+				ids[reservation.ID] = true
+				if reservation.OrderID != orderLine.OrderID || reservation.OrderLineID != orderLine.ID {
+					t.Errorf("reservation of %s belongs to order %s line %s, want order %s line %s",
+						reservation.ProductID, reservation.OrderID, reservation.OrderLineID, orderLine.OrderID, orderLine.ID)
+				}
+				if !reservation.ExpiresDateTime.Equal(expires) {
+					t.Errorf("reservation of %s expires %s, want %s", reservation.ProductID, reservation.ExpiresDateTime, expires)
+				}
+			}
+			if len(reservations) != len(tt.want) {
+				t.Errorf("%d reservations, want one per product (%d)", len(reservations), len(tt.want))
+			}
+			for productID, quantity := range tt.want {
+				if got[productID] != quantity {
+					t.Errorf("%d units of %s reserved, want %d", got[productID], productID, quantity)
+				}
+			}
+
+			// The order line took the first two IDs
+			for i := range reservations {
+				var id uuid.UUID
+				id[15] = byte(i + 3)
+				if !ids[id] {
+					t.Errorf("reservation ID %s was not used", id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrJobNotFound       = NewError(ErrNotFound, "job not found")
+	ErrJobAlreadyRunning = NewError(ErrConflict, "job is already running")
+)
+
+type JobTrigger string
+
+const (
+	JobTriggerSchedule JobTrigger = "schedule"
+	JobTriggerManual   JobTrigger = "manual"
+)
+
+type JobRunStatus string
+
+const (
+	JobRunStatusSucceeded JobRunStatus = "succeeded"
+	JobRunStatusFailed    JobRunStatus = "failed"
+	// JobRunStatusSkipped is recorded when a scheduled run is due while the
+	// previous run of the job is still going
+	JobRunStatusSkipped JobRunStatus = "skipped"
+)
+
+// JobRun records a single run of a background job.
+type JobRun struct {
+	ID               uuid.UUID    `json:"id"`
+	JobName          string       `json:"job_name"`
+	Trigger          JobTrigger   `json:"trigger"`
+	Status           JobRunStatus `json:"status"`
+	Error            string       `json:"error,omitempty"`
+	StartedDateTime  time.Time    `json:"started_date_time"`
+	FinishedDateTime time.Time    `json:"finished_date_time"`
+	DurationMs       int64        `json:"duration_ms"`
+}
+
+func StartJobRun(id uuid.UUID, jobName string, trigger JobTrigger, now time.Time) *JobRun {
+	return &JobRun{
+		ID:              id,
+		JobName:         jobName,
+		Trigger:         trigger,
+		StartedDateTime: now,
+	}
+}
+
+// Finish records the outcome of the run.
+func (r *JobRun) Finish(err error, now time.Time) {
+	r.FinishedDateTime = now
+	r.DurationMs = r.FinishedDateTime.Sub(r.StartedDateTime).Milliseconds()
+	r.Status = JobRunStatusSucceeded
+	if err != nil {
+		r.Status = JobRunStatusFailed
+		r.Error = err.Error()
+	}
+}
+
+// Skip records that the run did not happen.
+func (r *JobRun) Skip(reason string) {
+	r.FinishedDateTime = r.StartedDateTime
+	r.Status = JobRunStatusSkipped
+	r.Error = reason
+}
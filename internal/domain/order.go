@@ -1,22 +1,99 @@
 package domain
 
 import (
+	"fmt"
+	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrOrderNotOpen            = NewError(ErrConflict, "order is not open for changes")
+	ErrOrderNotDeletable       = NewError(ErrConflict, "only orders that have not been checked out can be deleted, cancel the order instead")
+	ErrOrderLineNotInOrder     = NewError(ErrNotFound, "order line does not belong to order")
+	ErrIllegalStatusTransition = NewError(ErrConflict, "illegal order status transition")
+	ErrSessionHasOrder         = NewError(ErrConflict, "session already has an order")
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusCreated      OrderStatus = "created"
+	OrderStatusCheckout     OrderStatus = "checkout"
+	OrderStatusPaid         OrderStatus = "paid"
+	OrderStatusInProduction OrderStatus = "in_production"
+	OrderStatusShipped      OrderStatus = "shipped"
+	OrderStatusDelivered    OrderStatus = "delivered"
+	OrderStatusCancelled    OrderStatus = "cancelled"
+	OrderStatusRefunded     OrderStatus = "refunded"
+)
+
+// orderStatusTransitions lists the statuses an order may move to from each
+// status. Cancelled and refunded are final.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusCreated:      {OrderStatusCheckout, OrderStatusCancelled},
+	OrderStatusCheckout:     {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:         {OrderStatusInProduction, OrderStatusRefunded},
+	OrderStatusInProduction: {OrderStatusShipped, OrderStatusRefunded},
+	OrderStatusShipped:      {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered:    {OrderStatusRefunded},
+	OrderStatusCancelled:    {},
+	OrderStatusRefunded:     {},
+}
+
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderStatusTransitions[s]
+	return ok
+}
+
+func (s OrderStatus) CanTransitionTo(status OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Actors recorded on status transitions that are not made by a known user.
+const (
+	ActorCustomer = "customer"
+	ActorStaff    = "staff"
+	ActorSystem   = "system"
+)
+
+// OrderStatusTransition records a single status change of an order.
+type OrderStatusTransition struct {
+	ID              uuid.UUID   `json:"id"`
+	OrderID         uuid.UUID   `json:"order_id"`
+	FromStatus      OrderStatus `json:"from_status"`
+	ToStatus        OrderStatus `json:"to_status"`
+	Actor           string      `json:"actor"`
+	CreatedDateTime time.Time   `json:"created_date_time"`
+}
+
 type Order struct {
-	ID              uuid.UUID `json:"id"`
-	SessionId       string    `json:"session_id"`
-	Email           string    `json:"email"`
-	Name            string    `json:"name"`
-	Address         string    `json:"address"`
-	ZipCode         string    `json:"zip_code"`
-	City            string    `json:"city"`
-	CompanyName     string    `json:"company_name"`
-	Status          string    `json:"status"`
-	CreatedDateTime time.Time `json:"created_date_time"`
+	ID              uuid.UUID   `json:"id"`
+	SessionId       string      `json:"session_id"`
+	CustomerID      *uuid.UUID  `json:"customer_id"`
+	Email           string      `json:"email"`
+	Name            string      `json:"name"`
+	Address         string      `json:"address"`
+	ZipCode         string      `json:"zip_code"`
+	City            string      `json:"city"`
+	CompanyName     string      `json:"company_name"`
+	OrderNumber     string      `json:"order_number"`
+	Status          OrderStatus `json:"status"`
+	CreatedDateTime time.Time   `json:"created_date_time"`
+	// Carts are abandoned when they haven't been changed for a while
+	LastActivityDateTime time.Time `json:"last_activity_date_time"`
+	// The amounts are fixed at checkout, open carts are priced when read
+	Subtotal int `json:"subtotal"`
+	Discount int `json:"discount"`
+	VAT      int `json:"vat"`
+	Total    int `json:"total"`
 }
 
 type CreateOrderInput struct {
@@ -31,15 +108,36 @@ type UpdateOrderInput struct {
 	ZipCode     *string `json:"zip_code"`
 	City        *string `json:"city"`
 	CompanyName *string `json:"company_name"`
-	Status      *string `json:"status"`
 }
 
-func CreateOrder(input CreateOrderInput) (*Order, error) {
+// OrphanedOrderRows counts rows removed because the order or order line they
+// belonged to no longer exists.
+type OrphanedOrderRows struct {
+	OrderLines        int64 `json:"order_lines"`
+	ContentLines      int64 `json:"content_lines"`
+	StatusTransitions int64 `json:"status_transitions"`
+	OrderNumbers      int64 `json:"order_numbers"`
+	Payments          int64 `json:"payments"`
+	StockReservations int64 `json:"stock_reservations"`
+}
+
+// CheckoutInput holds the customer details required to check out an order.
+type CheckoutInput struct {
+	Email       string `json:"email"`
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	ZipCode     string `json:"zip_code"`
+	City        string `json:"city"`
+	CompanyName string `json:"company_name"`
+}
+
+func CreateOrder(id uuid.UUID, input CreateOrderInput, now time.Time) (*Order, error) {
 	order := &Order{
-		ID:              uuid.New(),
-		SessionId:       input.SessionId,
-		CreatedDateTime: time.Now(),
-		Status:          "created",
+		ID:                   id,
+		SessionId:            input.SessionId,
+		CreatedDateTime:      now,
+		LastActivityDateTime: now,
+		Status:               OrderStatusCreated,
 	}
 
 	return order, nil
@@ -67,31 +165,138 @@ func (o *Order) Update(input UpdateOrderInput) error {
 	if input.CompanyName != nil {
 		o.CompanyName = *input.CompanyName
 	}
-	if input.Status != nil {
-		o.Status = *input.Status
+
+	return nil
+}
+
+// SetCustomerDetails validates and stores the customer details of a checkout.
+func (o *Order) SetCustomerDetails(input CheckoutInput) error {
+	validationErr := &ValidationError{}
+
+	email := strings.TrimSpace(input.Email)
+	if email == "" {
+		validationErr.Add("email", "email is required")
+	} else if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		validationErr.Add("email", "email is not a valid address")
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		validationErr.Add("name", "name is required")
+	}
+
+	streetAddress := strings.TrimSpace(input.Address)
+	if streetAddress == "" {
+		validationErr.Add("address", "address is required")
+	}
+
+	zipCode := strings.ReplaceAll(strings.TrimSpace(input.ZipCode), " ", "")
+	if zipCode == "" {
+		validationErr.Add("zip_code", "zip code is required")
+	} else if !isZipCode(zipCode) {
+		validationErr.Add("zip_code", "zip code must be five digits")
+	}
+
+	city := strings.TrimSpace(input.City)
+	if city == "" {
+		validationErr.Add("city", "city is required")
 	}
 
+	if err := validationErr.ErrOrNil(); err != nil {
+		return err
+	}
+
+	o.Email = email
+	o.Name = name
+	o.Address = streetAddress
+	o.ZipCode = zipCode
+	o.City = city
+	o.CompanyName = strings.TrimSpace(input.CompanyName)
+
 	return nil
 }
 
-func (o *Order) SetStatus(status string) error {
-	o.Status = status
+func isZipCode(zipCode string) bool {
+	if len(zipCode) != 5 {
+		return false
+	}
+	for _, r := range zipCode {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatOrderNumber turns a sequence number into the order number shown to
+// customers, e.g. 2025-000042.
+func FormatOrderNumber(sequence int64, at time.Time) string {
+	return fmt.Sprintf("%d-%06d", at.Year(), sequence)
+}
+
+// Checkout assigns the order number, fixes the amounts the customer pays and
+// moves the order out of the cart status so that it is no longer modified or
+// cleaned up.
+func (o *Order) Checkout(orderNumber string, prices PriceBreakdown, actor string, transitionID uuid.UUID, now time.Time) (*OrderStatusTransition, error) {
+	transition, err := o.TransitionTo(OrderStatusCheckout, actor, transitionID, now)
+	if err != nil {
+		return nil, err
+	}
+	o.OrderNumber = orderNumber
+	o.Subtotal = prices.Subtotal
+	o.Discount = prices.Discount
+	o.VAT = prices.VAT
+	o.Total = prices.Total
+
+	return transition, nil
+}
 
+// CheckTransition returns ErrIllegalStatusTransition if the order cannot move
+// to the given status.
+func (o *Order) CheckTransition(status OrderStatus) error {
+	if !o.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s to %s", ErrIllegalStatusTransition, o.Status, status)
+	}
 	return nil
 }
 
+// TransitionTo moves the order to the given status if the transition table
+// allows it and returns the transition to be recorded, with the given ID and
+// time.
+func (o *Order) TransitionTo(status OrderStatus, actor string, transitionID uuid.UUID, now time.Time) (*OrderStatusTransition, error) {
+	if err := o.CheckTransition(status); err != nil {
+		return nil, err
+	}
+
+	transition := &OrderStatusTransition{
+		ID:              transitionID,
+		OrderID:         o.ID,
+		FromStatus:      o.Status,
+		ToStatus:        status,
+		Actor:           actor,
+		CreatedDateTime: now,
+	}
+	o.Status = status
+
+	return transition, nil
+}
+
+// OrderLine.ProductName and OrderLineContentLine.ProductName are snapshots of
+// the catalog taken at checkout.
 type OrderLine struct {
-	ID        uuid.UUID `json:"id"`
-	OrderID   uuid.UUID `json:"order_id"`
-	ProductID uuid.UUID `json:"product_id"`
-	Price     int       `json:"price"`
-	Quantity  int       `json:"quantity"`
+	ID          uuid.UUID `json:"id"`
+	OrderID     uuid.UUID `json:"order_id"`
+	ProductID   uuid.UUID `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	Price       int       `json:"price"`
+	Quantity    int       `json:"quantity"`
 }
 
 type OrderLineContentLine struct {
 	ID          uuid.UUID `json:"id"`
 	OrderLineID uuid.UUID `json:"order_line_id"`
 	ProductID   uuid.UUID `json:"product_id"`
+	ProductName string    `json:"product_name"`
 	Quantity    int       `json:"quantity"`
 }
 
@@ -115,8 +320,93 @@ type CreateOrderLineContentLineInput struct {
 	Quantity    int       `json:"quantity"`
 }
 
-func CreateOrderLine(input CreateOrderLineInput) (*OrderLine, error) {
+// AddOrderLineInput describes a product being put into an order together with
+// the content lines of a configurable product, e.g. the pralines of a box.
+// The price is always taken from the catalog.
+type AddOrderLineInput struct {
+	ProductID    uuid.UUID                      `json:"product_id"`
+	Quantity     int                            `json:"quantity"`
+	ContentLines []AddOrderLineContentLineInput `json:"content_lines"`
+}
+
+type AddOrderLineContentLineInput struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+// ValidateOrderLineComposition checks that the product can be bought on its
+// own from productGroup, its product group, and that the content lines of an
+// order line form a valid composition of it. Content products are looked up
+// in contentProducts by ID.
+func ValidateOrderLineComposition(product *Product, productGroup *ProductGroup, contentLines []AddOrderLineContentLineInput, contentProducts map[uuid.UUID]Product) error {
+	validationErr := &ValidationError{}
+
+	if !product.IsSoldSeparately {
+		validationErr.Add("product_id", "product is not sold separately")
+	}
+	if !productGroup.IsSold {
+		validationErr.Add("product_id", "product group of the product is not sold")
+	}
+
+	if !product.IsConfigurable {
+		if len(contentLines) > 0 {
+			validationErr.Add("content_lines", "product is not configurable and cannot have content lines")
+		}
+		return validationErr.ErrOrNil()
+	}
+
+	if product.ConfiguredByProductGroupID == nil {
+		validationErr.Add("product_id", "configurable product has no configuring product group")
+		return validationErr
+	}
+
+	totalQuantity := 0
+	for i, contentLine := range contentLines {
+		field := fmt.Sprintf("content_lines[%d]", i)
+
+		if contentLine.Quantity <= 0 {
+			validationErr.Add(field+".quantity", "quantity must be positive")
+		}
+		totalQuantity += contentLine.Quantity
+
+		contentProduct, ok := contentProducts[contentLine.ProductID]
+		if !ok {
+			validationErr.Addf(field+".product_id", "product %s does not exist", contentLine.ProductID)
+			continue
+		}
+		if contentProduct.ProductGroupID != *product.ConfiguredByProductGroupID {
+			validationErr.Addf(field+".product_id", "product %s is not part of the configuring product group", contentLine.ProductID)
+		}
+		if contentProduct.IsConfigurable {
+			validationErr.Addf(field+".product_id", "product %s is configurable and cannot be used as content", contentLine.ProductID)
+		}
+	}
+
+	if totalQuantity != product.ConfiguredQuantity {
+		validationErr.Addf("content_lines", "content quantity is %d but the product must contain exactly %d", totalQuantity, product.ConfiguredQuantity)
+	}
+
+	return validationErr.ErrOrNil()
+}
+
+type UpdateOrderLineQuantityInput struct {
+	Quantity int `json:"quantity"`
+}
+
+func CreateOrderLine(id uuid.UUID, input CreateOrderLineInput) (*OrderLine, error) {
+	validationErr := &ValidationError{}
+	if input.Quantity <= 0 {
+		validationErr.Add("quantity", "order line quantity must be positive")
+	}
+	if input.Price < 0 {
+		validationErr.Add("price", "order line price cannot be negative")
+	}
+	if err := validationErr.ErrOrNil(); err != nil {
+		return nil, err
+	}
+
 	orderLine := &OrderLine{
+		ID:        id,
 		OrderID:   input.OrderID,
 		ProductID: input.ProductID,
 		Price:     input.Price,
@@ -127,13 +417,26 @@ func CreateOrderLine(input CreateOrderLineInput) (*OrderLine, error) {
 }
 
 func (ol *OrderLine) UpdateQuantity(quantity int) error {
+	if quantity <= 0 {
+		validationErr := &ValidationError{}
+		validationErr.Add("quantity", "order line quantity must be positive")
+		return validationErr
+	}
+
 	ol.Quantity = quantity
 
 	return nil
 }
 
-func CreateOrderLineContentLine(input CreateOrderLineContentLineInput) (*OrderLineContentLine, error) {
+func CreateOrderLineContentLine(id uuid.UUID, input CreateOrderLineContentLineInput) (*OrderLineContentLine, error) {
+	if input.Quantity <= 0 {
+		validationErr := &ValidationError{}
+		validationErr.Add("quantity", "content line quantity must be positive")
+		return nil, validationErr
+	}
+
 	orderLineContentLine := &OrderLineContentLine{
+		ID:          id,
 		OrderLineID: input.OrderLineID,
 		ProductID:   input.ProductID,
 		Quantity:    input.Quantity,
@@ -141,3 +444,26 @@ func CreateOrderLineContentLine(input CreateOrderLineContentLineInput) (*OrderLi
 
 	return orderLineContentLine, nil
 }
+
+// Touch records that the cart was changed.
+func (o *Order) Touch(now time.Time) {
+	o.LastActivityDateTime = now
+}
+
+// IsOpen reports whether the order is still a cart that can be modified.
+func (o *Order) IsOpen() bool {
+	return o.Status == OrderStatusCreated
+}
+
+// AssignCustomer makes the cart the cart of a customer.
+func (o *Order) AssignCustomer(customerID uuid.UUID) error {
+	if !o.IsOpen() {
+		return ErrOrderNotOpen
+	}
+	if o.CustomerID != nil && *o.CustomerID != customerID {
+		return ErrCartOfOtherCustomer
+	}
+
+	o.CustomerID = &customerID
+	return nil
+}
@@ -0,0 +1,83 @@
+package domain_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+func TestValidateOrderLineComposition(t *testing.T) {
+	boxes := uuid.MustParse("00000000-0000-0000-0000-0000000000b1")
+	pralines := uuid.MustParse("00000000-0000-0000-0000-0000000000b2")
+	praline := domain.Product{ID: uuid.MustParse("00000000-0000-0000-0000-0000000000a1"), ProductGroupID: pralines}
+	box := domain.Product{
+		ID:                         uuid.MustParse("00000000-0000-0000-0000-0000000000a2"),
+		ProductGroupID:             boxes,
+		IsSoldSeparately:           true,
+		IsConfigurable:             true,
+		ConfiguredByProductGroupID: &pralines,
+		ConfiguredQuantity:         4,
+	}
+	bar := domain.Product{ID: uuid.MustParse("00000000-0000-0000-0000-0000000000a3"), ProductGroupID: boxes, IsSoldSeparately: true}
+	contentProducts := map[uuid.UUID]domain.Product{praline.ID: praline}
+
+	tests := []struct {
+		name         string
+		product      domain.Product
+		groupIsSold  bool
+		contentLines []domain.AddOrderLineContentLineInput
+		wantFields   []string
+	}{
+		{name: "plain product", product: bar, groupIsSold: true},
+		{
+			name:         "configured product",
+			product:      box,
+			groupIsSold:  true,
+			contentLines: []domain.AddOrderLineContentLineInput{{ProductID: praline.ID, Quantity: 4}},
+		},
+		{name: "product only sold as content", product: praline, groupIsSold: true, wantFields: []string{"product_id"}},
+		{name: "product group not sold", product: bar, groupIsSold: false, wantFields: []string{"product_id"}},
+		{
+			name:         "content of plain product",
+			product:      bar,
+			groupIsSold:  true,
+			contentLines: []domain.AddOrderLineContentLineInput{{ProductID: praline.ID, Quantity: 1}},
+			wantFields:   []string{"content_lines"},
+		},
+		{
+			name:         "too few content units",
+			product:      box,
+			groupIsSold:  true,
+			contentLines: []domain.AddOrderLineContentLineInput{{ProductID: praline.ID, Quantity: 3}},
+			wantFields:   []string{"content_lines"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			productGroup := &domain.ProductGroup{ID: tt.product.ProductGroupID, IsSold: tt.groupIsSold}
+			err := domain.ValidateOrderLineComposition(&tt.product, productGroup, tt.contentLines, contentProducts)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var validationErr *domain.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("error = %v, want a validation error", err)
+			}
+			var fields []string
+			for _, violation := range validationErr.Violations {
+				fields = append(fields, violation.Field)
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("violated fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
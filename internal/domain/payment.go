@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const CurrencySEK = "SEK"
+
+var (
+	ErrOrderNotAwaitingPayment = NewError(ErrConflict, "order is not awaiting payment")
+	ErrPaymentNotPending       = NewError(ErrConflict, "payment is not pending")
+	ErrPaymentNotCaptured      = NewError(ErrConflict, "payment is not captured")
+	ErrPaymentNotSettling      = NewError(ErrConflict, "payment is not waiting for the payment provider")
+	ErrNoCapturedPayment       = NewError(ErrConflict, "order has no captured payment")
+)
+
+// PaymentStatus is the state of a payment. Capturing, voiding and refunding
+// are recorded before the payment provider is asked to act and are left for
+// captured, voided and refunded once it has.
+type PaymentStatus string
+
+const (
+	PaymentStatusPending   PaymentStatus = "pending"
+	PaymentStatusCapturing PaymentStatus = "capturing"
+	PaymentStatusCaptured  PaymentStatus = "captured"
+	PaymentStatusFailed    PaymentStatus = "failed"
+	PaymentStatusVoiding   PaymentStatus = "voiding"
+	PaymentStatusVoided    PaymentStatus = "voided"
+	PaymentStatusRefunding PaymentStatus = "refunding"
+	PaymentStatusRefunded  PaymentStatus = "refunded"
+)
+
+// Payment is a single attempt to pay for an order through a payment provider.
+type Payment struct {
+	ID                uuid.UUID     `json:"id"`
+	OrderID           uuid.UUID     `json:"order_id"`
+	Provider          string        `json:"provider"`
+	ProviderReference string        `json:"provider_reference"`
+	Amount            int           `json:"amount"`
+	Currency          string        `json:"currency"`
+	Status            PaymentStatus `json:"status"`
+	RedirectURL       string        `json:"redirect_url"`
+	CreatedDateTime   time.Time     `json:"created_date_time"`
+	UpdatedDateTime   time.Time     `json:"updated_date_time"`
+}
+
+// PaymentIntent is returned by a payment provider when a payment is started.
+// RedirectURL is where the customer completes the payment, if the provider
+// uses one.
+type PaymentIntent struct {
+	Reference   string `json:"reference"`
+	RedirectURL string `json:"redirect_url"`
+}
+
+// PaymentCallback is the provider independent result of a payment callback.
+type PaymentCallback struct {
+	Reference  string
+	Authorized bool
+}
+
+func CreatePayment(id uuid.UUID, orderID uuid.UUID, provider string, intent *PaymentIntent, amount int, currency string, now time.Time) (*Payment, error) {
+	if amount <= 0 {
+		return nil, NewError(ErrValidation, "payment amount must be positive")
+	}
+	if intent.Reference == "" {
+		return nil, NewError(ErrValidation, "payment reference cannot be empty")
+	}
+
+	payment := &Payment{
+		ID:                id,
+		OrderID:           orderID,
+		Provider:          provider,
+		ProviderReference: intent.Reference,
+		Amount:            amount,
+		Currency:          currency,
+		Status:            PaymentStatusPending,
+		RedirectURL:       intent.RedirectURL,
+		CreatedDateTime:   now,
+		UpdatedDateTime:   now,
+	}
+
+	return payment, nil
+}
+
+func (p *Payment) IsPending() bool {
+	return p.Status == PaymentStatusPending
+}
+
+// IsSettling reports whether the payment waits for the payment provider to
+// capture, void or refund it.
+func (p *Payment) IsSettling() bool {
+	switch p.Status {
+	case PaymentStatusCapturing, PaymentStatusVoiding, PaymentStatusRefunding:
+		return true
+	}
+	return false
+}
+
+// MarkCapturing records that an authorized payment is to be captured.
+func (p *Payment) MarkCapturing(now time.Time) error {
+	if !p.IsPending() {
+		return ErrPaymentNotPending
+	}
+	p.Status = PaymentStatusCapturing
+	p.UpdatedDateTime = now
+
+	return nil
+}
+
+// MarkVoiding records that an authorized payment is to be released without
+// being captured, e.g. because the order was already paid.
+func (p *Payment) MarkVoiding(now time.Time) error {
+	if !p.IsPending() {
+		return ErrPaymentNotPending
+	}
+	p.Status = PaymentStatusVoiding
+	p.UpdatedDateTime = now
+
+	return nil
+}
+
+func (p *Payment) MarkFailed(now time.Time) error {
+	if !p.IsPending() {
+		return ErrPaymentNotPending
+	}
+	p.Status = PaymentStatusFailed
+	p.UpdatedDateTime = now
+
+	return nil
+}
+
+// MarkRefunding records that a captured payment is to be refunded.
+func (p *Payment) MarkRefunding(now time.Time) error {
+	if p.Status != PaymentStatusCaptured {
+		return ErrPaymentNotCaptured
+	}
+	p.Status = PaymentStatusRefunding
+	p.UpdatedDateTime = now
+
+	return nil
+}
+
+// MarkSettled records that the payment provider has captured, voided or
+// refunded the payment.
+func (p *Payment) MarkSettled(now time.Time) error {
+	switch p.Status {
+	case PaymentStatusCapturing:
+		p.Status = PaymentStatusCaptured
+	case PaymentStatusVoiding:
+		p.Status = PaymentStatusVoided
+	case PaymentStatusRefunding:
+		p.Status = PaymentStatusRefunded
+	default:
+		return ErrPaymentNotSettling
+	}
+	p.UpdatedDateTime = now
+
+	return nil
+}
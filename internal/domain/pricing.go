@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// All prices are in the smallest currency unit (öre) and include VAT.
+
+// Discount reduces the order subtotal once it reaches MinSubtotal. Either
+// PercentOff or AmountOff is applied, whichever is set.
+type Discount struct {
+	Name        string `json:"name"`
+	MinSubtotal int    `json:"min_subtotal"`
+	PercentOff  int    `json:"percent_off"`
+	AmountOff   int    `json:"amount_off"`
+}
+
+type LinePrice struct {
+	OrderLineID uuid.UUID `json:"order_line_id"`
+	UnitPrice   int       `json:"unit_price"`
+	Quantity    int       `json:"quantity"`
+	Total       int       `json:"total"`
+}
+
+type PriceBreakdown struct {
+	Lines    []LinePrice `json:"lines"`
+	Subtotal int         `json:"subtotal"`
+	Discount int         `json:"discount"`
+	VAT      int         `json:"vat"`
+	Total    int         `json:"total"`
+}
+
+type PriceCalculator struct {
+	vatRatePercent int
+	discounts      []Discount
+}
+
+func NewPriceCalculator(vatRatePercent int, discounts []Discount) (*PriceCalculator, error) {
+	if vatRatePercent < 0 {
+		return nil, errors.New("VAT rate cannot be negative")
+	}
+	for _, discount := range discounts {
+		if discount.PercentOff < 0 || discount.PercentOff > 100 {
+			return nil, errors.New("discount percentage must be between 0 and 100")
+		}
+		if discount.AmountOff < 0 {
+			return nil, errors.New("discount amount cannot be negative")
+		}
+	}
+
+	return &PriceCalculator{
+		vatRatePercent: vatRatePercent,
+		discounts:      discounts,
+	}, nil
+}
+
+// UnitPrice returns the catalog price of one unit of the product. Content
+// lines of configurable products are included in the product price.
+func (c *PriceCalculator) UnitPrice(product *Product) int {
+	return product.Price
+}
+
+// Calculate computes line totals, subtotal, discount, VAT and grand total
+// from the prices stored on the order lines.
+func (c *PriceCalculator) Calculate(orderLines []*OrderLine) PriceBreakdown {
+	breakdown := PriceBreakdown{
+		Lines: make([]LinePrice, len(orderLines)),
+	}
+
+	for i, orderLine := range orderLines {
+		lineTotal := orderLine.Price * orderLine.Quantity
+		breakdown.Lines[i] = LinePrice{
+			OrderLineID: orderLine.ID,
+			UnitPrice:   orderLine.Price,
+			Quantity:    orderLine.Quantity,
+			Total:       lineTotal,
+		}
+		breakdown.Subtotal += lineTotal
+	}
+
+	breakdown.Discount = c.bestDiscount(breakdown.Subtotal)
+	breakdown.Total = breakdown.Subtotal - breakdown.Discount
+	breakdown.VAT = c.includedVAT(breakdown.Total)
+
+	return breakdown
+}
+
+// bestDiscount returns the largest applicable discount. Discounts do not stack.
+func (c *PriceCalculator) bestDiscount(subtotal int) int {
+	best := 0
+	for _, discount := range c.discounts {
+		if subtotal < discount.MinSubtotal {
+			continue
+		}
+
+		amount := discount.AmountOff
+		if discount.PercentOff > 0 {
+			amount = subtotal * discount.PercentOff / 100
+		}
+		if amount > best {
+			best = amount
+		}
+	}
+
+	if best > subtotal {
+		return subtotal
+	}
+	return best
+}
+
+// includedVAT returns the VAT part of a VAT-inclusive amount, rounded to the
+// nearest unit.
+func (c *PriceCalculator) includedVAT(amount int) int {
+	divisor := 100 + c.vatRatePercent
+	return (amount*c.vatRatePercent + divisor/2) / divisor
+}
@@ -1,11 +1,14 @@
 package domain
 
 import (
-	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+var ErrProductGroupInUse = NewError(ErrConflict, "product group is in use")
+
 type ProductGroup struct {
 	ID     uuid.UUID `json:"id"`
 	Name   string    `json:"name"`
@@ -25,6 +28,56 @@ type UpdateProductGroupInput struct {
 	IsSold *bool   `json:"is_sold"`
 }
 
+// DeleteProductGroupInput says what happens to the products of a product group
+// that is deleted. Without Cascade or ReassignTo a group that is in use is not
+// deleted.
+type DeleteProductGroupInput struct {
+	// Cascade deletes the products in the group and turns the products it
+	// configures into plain products
+	Cascade bool
+	// ReassignTo moves the products in the group, and the products it
+	// configures, to another group
+	ReassignTo *uuid.UUID
+}
+
+func (input DeleteProductGroupInput) Validate(productGroupID uuid.UUID) error {
+	validationErr := &ValidationError{}
+	if input.Cascade && input.ReassignTo != nil {
+		validationErr.Add("reassign_to", "cannot both cascade and reassign")
+	}
+	if input.ReassignTo != nil && *input.ReassignTo == productGroupID {
+		validationErr.Add("reassign_to", "cannot reassign to the deleted product group")
+	}
+
+	return validationErr.ErrOrNil()
+}
+
+type ProductGroupOrder struct {
+	ID    uuid.UUID `json:"id"`
+	Order int       `json:"order"`
+}
+
+type ReorderProductGroupsInput struct {
+	ProductGroups []ProductGroupOrder `json:"product_groups"`
+}
+
+func (input ReorderProductGroupsInput) Validate() error {
+	validationErr := &ValidationError{}
+	if len(input.ProductGroups) == 0 {
+		validationErr.Add("product_groups", "at least one product group is required")
+	}
+
+	seen := make(map[uuid.UUID]bool, len(input.ProductGroups))
+	for i, productGroupOrder := range input.ProductGroups {
+		if seen[productGroupOrder.ID] {
+			validationErr.Addf(fmt.Sprintf("product_groups[%d].id", i), "product group %s is listed more than once", productGroupOrder.ID)
+		}
+		seen[productGroupOrder.ID] = true
+	}
+
+	return validationErr.ErrOrNil()
+}
+
 type Product struct {
 	ID                         uuid.UUID  `json:"id"`
 	Name                       string     `json:"name"`
@@ -59,19 +112,9 @@ type UpdateProductInput struct {
 	IsSoldSeparately           *bool      `json:"is_sold_separately"`
 }
 
-func CreateProduct(input CreateProductInput) (*Product, error) {
-	if input.Name == "" {
-		return nil, errors.New("product name cannot be empty")
-	}
-	if input.Price < 0 {
-		return nil, errors.New("product price cannot be negative")
-	}
-	if input.ConfiguredQuantity < 0 {
-		return nil, errors.New("configured quantity cannot be negative")
-	}
-
+func CreateProduct(id uuid.UUID, input CreateProductInput) (*Product, error) {
 	product := Product{
-		ID:                         uuid.New(),
+		ID:                         id,
 		Name:                       input.Name,
 		Price:                      input.Price,
 		ProductGroupID:             input.ProductGroupID,
@@ -82,64 +125,141 @@ func CreateProduct(input CreateProductInput) (*Product, error) {
 		IsSoldSeparately:           input.IsSoldSeparately,
 	}
 
+	err := product.validate()
+	if err != nil {
+		return nil, err
+	}
+
 	return &product, nil
 }
 
+// validate checks the rules every product follows, reporting each broken rule
+// against the input field it came from.
+func (p *Product) validate() error {
+	validationErr := &ValidationError{}
+	if strings.TrimSpace(p.Name) == "" {
+		validationErr.Add("name", "name is required")
+	}
+	if p.Price < 0 {
+		validationErr.Add("price", "price cannot be negative")
+	}
+	if p.ProductGroupID == uuid.Nil {
+		validationErr.Add("product_group_id", "product group is required")
+	}
+	if p.ConfiguredQuantity < 0 {
+		validationErr.Add("configured_quantity", "configured quantity cannot be negative")
+	}
+	if p.IsConfigurable {
+		if p.ConfiguredByProductGroupID == nil {
+			validationErr.Add("configured_by_product_group_id", "configurable products need a configuring product group")
+		}
+		if p.ConfiguredQuantity == 0 {
+			validationErr.Add("configured_quantity", "configurable products need a configured quantity")
+		}
+	}
+
+	return validationErr.ErrOrNil()
+}
+
 func (p *Product) Update(input UpdateProductInput) error {
+	updated := *p
 	if input.Name != nil {
-		p.Name = *input.Name
+		updated.Name = *input.Name
 	}
 	if input.Price != nil {
-		p.Price = *input.Price
+		updated.Price = *input.Price
 	}
 	if input.Order != nil {
-		p.Order = *input.Order
+		updated.Order = *input.Order
 	}
 	if input.IsConfigurable != nil {
-		p.IsConfigurable = *input.IsConfigurable
+		updated.IsConfigurable = *input.IsConfigurable
 	}
 	if input.ConfiguredByProductGroupID != nil {
-		p.ConfiguredByProductGroupID = input.ConfiguredByProductGroupID
+		updated.ConfiguredByProductGroupID = input.ConfiguredByProductGroupID
 	}
 	if input.ConfiguredQuantity != nil {
-		p.ConfiguredQuantity = *input.ConfiguredQuantity
+		updated.ConfiguredQuantity = *input.ConfiguredQuantity
 	}
 	if input.IsSoldSeparately != nil {
-		p.IsSoldSeparately = *input.IsSoldSeparately
+		updated.IsSoldSeparately = *input.IsSoldSeparately
 	}
 
+	err := updated.validate()
+	if err != nil {
+		return err
+	}
+
+	*p = updated
+
 	return nil
 }
 
-func CreateProductGroup(input CreateProductGroupInput) (*ProductGroup, error) {
-	if input.Name == "" {
-		return nil, errors.New("product group name cannot be empty")
-	}
-
+func CreateProductGroup(id uuid.UUID, input CreateProductGroupInput) (*ProductGroup, error) {
 	productGroup := ProductGroup{
-		ID:     uuid.New(),
+		ID:     id,
 		Name:   input.Name,
 		Order:  input.Order,
 		IsSold: input.IsSold,
 	}
 
+	err := productGroup.validate()
+	if err != nil {
+		return nil, err
+	}
+
 	return &productGroup, nil
 }
 
+func (pg *ProductGroup) validate() error {
+	validationErr := &ValidationError{}
+	if strings.TrimSpace(pg.Name) == "" {
+		validationErr.Add("name", "name is required")
+	}
+
+	return validationErr.ErrOrNil()
+}
+
 func (pg *ProductGroup) Update(input UpdateProductGroupInput) error {
+	updated := *pg
 	if input.Name != nil {
-		pg.Name = *input.Name
+		updated.Name = *input.Name
 	}
 	if input.Order != nil {
-		pg.Order = *input.Order
+		updated.Order = *input.Order
 	}
 	if input.IsSold != nil {
-		pg.IsSold = *input.IsSold
+		updated.IsSold = *input.IsSold
 	}
 
+	err := updated.validate()
+	if err != nil {
+		return err
+	}
+
+	*pg = updated
+
 	return nil
 }
 
+// MoveProductGroup points every reference the product has to the product
+// group from at the product group to instead.
+func (p *Product) MoveProductGroup(from uuid.UUID, to uuid.UUID) {
+	if p.ProductGroupID == from {
+		p.ProductGroupID = to
+	}
+	if p.ConfiguredByProductGroupID != nil && *p.ConfiguredByProductGroupID == from {
+		p.ConfiguredByProductGroupID = &to
+	}
+}
+
+// StopConfiguration turns a configurable product into a plain product.
+func (p *Product) StopConfiguration() {
+	p.IsConfigurable = false
+	p.ConfiguredByProductGroupID = nil
+	p.ConfiguredQuantity = 0
+}
+
 type ProductGroupWithProducts struct {
 	ProductGroup ProductGroup `json:"product_group"`
 	Products     []Product    `json:"products"`
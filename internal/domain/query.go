@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Page selects a window of a sorted list.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// Sort orders a list by a field. Sorts are written as the field name, with a
+// leading "-" for descending order, e.g. "-price".
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// ParseSort parses a sort written as "field" or "-field". An empty value gives
+// defaultSort.
+func ParseSort(value string, defaultSort Sort, allowedFields ...string) (Sort, error) {
+	if value == "" {
+		return defaultSort, nil
+	}
+
+	sort := Sort{Field: strings.TrimPrefix(value, "-"), Descending: strings.HasPrefix(value, "-")}
+	for _, field := range allowedFields {
+		if sort.Field == field {
+			return sort, nil
+		}
+	}
+
+	validationErr := &ValidationError{}
+	validationErr.Addf("sort", "cannot sort by %q, use one of %s", sort.Field, strings.Join(allowedFields, ", "))
+	return Sort{}, validationErr
+}
+
+func (p Page) validate(validationErr *ValidationError) {
+	if p.Offset < 0 {
+		validationErr.Add("offset", "offset cannot be negative")
+	}
+	if p.Limit < 0 || p.Limit > MaxPageLimit {
+		validationErr.Addf("limit", "limit must be between 1 and %d", MaxPageLimit)
+	}
+}
+
+// withDefaults fills in the default limit when none was given.
+func (p Page) withDefaults() Page {
+	if p.Limit == 0 {
+		p.Limit = DefaultPageLimit
+	}
+	return p
+}
+
+// Normalize validates a page that is used on its own and fills in the
+// default limit.
+func (p Page) Normalize() (Page, error) {
+	validationErr := &ValidationError{}
+	p.validate(validationErr)
+	if err := validationErr.ErrOrNil(); err != nil {
+		return p, err
+	}
+	return p.withDefaults(), nil
+}
+
+var (
+	ProductSortFields      = []string{"order", "name", "price"}
+	ProductGroupSortFields = []string{"order", "name"}
+	DefaultSort            = Sort{Field: "order"}
+)
+
+// ProductQuery filters, sorts and pages a list of products. Nil filters match
+// every product.
+type ProductQuery struct {
+	ProductGroupID   *uuid.UUID
+	IsSold           *bool
+	IsConfigurable   *bool
+	IsSoldSeparately *bool
+	MinPrice         *int
+	MaxPrice         *int
+	Sort             Sort
+	Page             Page
+}
+
+// Normalize validates the query and fills in defaults.
+func (q ProductQuery) Normalize() (ProductQuery, error) {
+	validationErr := &ValidationError{}
+	q.Page.validate(validationErr)
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		validationErr.Add("min_price", "min price cannot be above max price")
+	}
+	if err := validationErr.ErrOrNil(); err != nil {
+		return q, err
+	}
+
+	q.Page = q.Page.withDefaults()
+	if q.Sort.Field == "" {
+		q.Sort = DefaultSort
+	}
+	return q, nil
+}
+
+// ProductGroupQuery filters, sorts and pages a list of product groups.
+type ProductGroupQuery struct {
+	IsSold *bool
+	Sort   Sort
+	Page   Page
+}
+
+// Normalize validates the query and fills in defaults.
+func (q ProductGroupQuery) Normalize() (ProductGroupQuery, error) {
+	validationErr := &ValidationError{}
+	q.Page.validate(validationErr)
+	if err := validationErr.ErrOrNil(); err != nil {
+		return q, err
+	}
+
+	q.Page = q.Page.withDefaults()
+	if q.Sort.Field == "" {
+		q.Sort = DefaultSort
+	}
+	return q, nil
+}
+
+var (
+	OrderSortFields  = []string{"created_date_time", "order_number", "name", "email"}
+	DefaultOrderSort = Sort{Field: "created_date_time", Descending: true}
+)
+
+// OrderQuery filters, sorts and pages a list of orders. Empty filters match
+// every order.
+type OrderQuery struct {
+	CustomerID  *uuid.UUID
+	Statuses    []OrderStatus
+	CreatedFrom *time.Time
+	// CreatedBefore is exclusive
+	CreatedBefore *time.Time
+	Email         string
+	ZipCode       string
+	CompanyName   string
+	// Search matches any part of the customer fields and the order number
+	Search string
+	Sort   Sort
+	Page   Page
+}
+
+// Normalize validates the query and fills in defaults.
+func (q OrderQuery) Normalize() (OrderQuery, error) {
+	validationErr := &ValidationError{}
+	q.Page.validate(validationErr)
+	for _, status := range q.Statuses {
+		if !status.IsValid() {
+			validationErr.Addf("status", "unknown order status %q", status)
+		}
+	}
+	if q.CreatedFrom != nil && q.CreatedBefore != nil && !q.CreatedFrom.Before(*q.CreatedBefore) {
+		validationErr.Add("created_from", "created_from must be before created_before")
+	}
+	if err := validationErr.ErrOrNil(); err != nil {
+		return q, err
+	}
+
+	q.Page = q.Page.withDefaults()
+	q.ZipCode = strings.ReplaceAll(q.ZipCode, " ", "")
+	if q.Sort.Field == "" {
+		q.Sort = DefaultOrderSort
+	}
+	return q, nil
+}
@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a job runs next.
+type Schedule interface {
+	// Next returns the first run time after the given time
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// ParseSchedule parses "@every <duration>", as in "@every 5m", or a cron
+// expression with the five fields minute, hour, day of month, month and day
+// of week.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if interval, found := strings.CutPrefix(spec, "@every "); found {
+		duration, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil {
+			return nil, Errorf(ErrInvalidInput, "invalid schedule %q: %v", spec, err)
+		}
+		if duration <= 0 {
+			return nil, Errorf(ErrInvalidInput, "invalid schedule %q: interval must be positive", spec)
+		}
+		return IntervalSchedule{Interval: duration}, nil
+	}
+
+	return ParseCron(spec)
+}
+
+// IntervalSchedule runs a job at a fixed interval after the previous run.
+type IntervalSchedule struct {
+	Interval time.Duration
+}
+
+func (s IntervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.Interval)
+}
+
+func (s IntervalSchedule) String() string {
+	return "@every " + s.Interval.String()
+}
+
+// CronSchedule runs a job on the minutes matched by a cron expression, in
+// local time. As in cron, when both the day of month and the day of week are
+// restricted a day matching either is run on.
+type CronSchedule struct {
+	expression string
+	minutes    uint64
+	hours      uint64
+	days       uint64
+	months     uint64
+	weekdays   uint64
+	// anyDay is set when the day of month or the day of week is *
+	anyDay bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, Errorf(ErrInvalidInput, "invalid cron expression %q: expected 5 fields, got %d", expression, len(fields))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, Errorf(ErrInvalidInput, "invalid cron expression %q: %v", expression, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	weekdays := sets[4]
+	if weekdays&(1<<7) != 0 {
+		weekdays |= 1
+	}
+
+	return &CronSchedule{
+		expression: expression,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   weekdays,
+		anyDay:     fields[2] == "*" || fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma separated list of *, values and ranges, each
+// optionally with a step, as in "*/15" or "1-5,10".
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepPart, spec.name)
+			}
+		}
+
+		low, high := spec.min, spec.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			low, err = strconv.Atoi(lowPart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s", lowPart, spec.name)
+			}
+			high = low
+			if isRange {
+				high, err = strconv.Atoi(highPart)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s", highPart, spec.name)
+				}
+			} else if hasStep {
+				high = spec.max
+			}
+		}
+		if low < spec.min || high > spec.max || low > high {
+			return 0, fmt.Errorf("%s must be between %d and %d", spec.name, spec.min, spec.max)
+		}
+
+		for value := low; value <= high; value += step {
+			set |= 1 << value
+		}
+	}
+	return set, nil
+}
+
+// maxCronSearch bounds the search for the next run of expressions that match
+// rarely or never, such as the 31st of February.
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronSearch)
+
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return limit
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayMatches := s.days&(1<<uint(t.Day())) != 0
+	weekdayMatches := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.anyDay {
+		return dayMatches && weekdayMatches
+	}
+	return dayMatches || weekdayMatches
+}
+
+func (s *CronSchedule) String() string {
+	return s.expression
+}
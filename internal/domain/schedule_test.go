@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2 March 2026 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{name: "every minute", expression: "* * * * *", after: at(2, 10, 7), want: at(2, 10, 8)},
+		{name: "strictly after", expression: "0 * * * *", after: at(2, 10, 0), want: at(2, 11, 0)},
+		{name: "seconds are dropped", expression: "0 * * * *", after: at(2, 10, 0).Add(30 * time.Second), want: at(2, 11, 0)},
+		{name: "step", expression: "*/15 * * * *", after: at(2, 10, 7), want: at(2, 10, 15)},
+		{name: "step into next hour", expression: "*/15 * * * *", after: at(2, 10, 45), want: at(2, 11, 0)},
+		{name: "value with step", expression: "5/20 * * * *", after: at(2, 10, 30), want: at(2, 10, 45)},
+		{name: "range with step", expression: "0 9-17/4 * * *", after: at(2, 10, 0), want: at(2, 13, 0)},
+		{name: "range with step into next day", expression: "0 9-17/4 * * *", after: at(2, 17, 0), want: at(3, 9, 0)},
+		{name: "list and weekday range", expression: "0,30 8 * * 1-5", after: at(6, 8, 30), want: at(9, 8, 0)},
+		{name: "day of month", expression: "30 2 1 * *", after: at(2, 0, 0), want: time.Date(2026, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{name: "day of month with any weekday", expression: "0 0 10 * *", after: at(2, 0, 0), want: at(10, 0, 0)},
+		{name: "weekday with any day of month", expression: "0 0 * * 5", after: at(2, 0, 0), want: at(6, 0, 0)},
+		{name: "day of month or weekday, weekday first", expression: "0 0 10 * 5", after: at(2, 0, 0), want: at(6, 0, 0)},
+		{name: "day of month or weekday, day of month first", expression: "0 0 10 * 5", after: at(6, 0, 0), want: at(10, 0, 0)},
+		{name: "day of month or weekday, weekday again", expression: "0 0 10 * 5", after: at(10, 0, 0), want: at(13, 0, 0)},
+		{name: "sunday as 0", expression: "0 12 * * 0", after: at(2, 0, 0), want: at(8, 12, 0)},
+		{name: "sunday as 7", expression: "0 12 * * 7", after: at(2, 0, 0), want: at(8, 12, 0)},
+		{name: "range ending on sunday as 7", expression: "0 12 * * 5-7", after: at(7, 12, 0), want: at(8, 12, 0)},
+		{name: "leap day", expression: "0 0 29 2 *", after: at(2, 0, 0), want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "@every 5m", want: "@every 5m0s"},
+		{spec: " */5 * * * * ", want: "*/5 * * * *"},
+		{spec: "@every 0s", wantErr: true},
+		{spec: "@every soon", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("error = %v, want %v", err, ErrInvalidInput)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Violation describes a single failed validation rule. Field is a path into
+// the input, e.g. "content_lines[2].product_id".
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every violation found while validating an input
+// so that clients can report all problems at once.
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Add(field string, message string) {
+	e.Violations = append(e.Violations, Violation{Field: field, Message: message})
+}
+
+func (e *ValidationError) Addf(field string, format string, args ...interface{}) {
+	e.Add(field, fmt.Sprintf(format, args...))
+}
+
+// ErrOrNil returns the validation error if any violations were added.
+func (e *ValidationError) ErrOrNil() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Is makes every ValidationError match ErrValidation.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Field + ": " + violation.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
@@ -0,0 +1,132 @@
+// Package lifecycle runs the long lived parts of a process and shuts them
+// down in order when the process is asked to stop.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/ports"
+)
+
+type worker struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager starts workers, such as the HTTP server and background jobs, and
+// stops them on SIGINT or SIGTERM, or as soon as one of them fails. Workers
+// are told to stop by cancelling their context and get the shutdown timeout
+// to return. The shutdown hooks, such as closing the database, run after
+// that in reverse order of registration.
+type Manager struct {
+	shutdownTimeout time.Duration
+	workers         []worker
+	hooks           []hook
+	logger          ports.Logger
+}
+
+func NewManager(shutdownTimeout time.Duration, logger ports.Logger) *Manager {
+	return &Manager{
+		shutdownTimeout: shutdownTimeout,
+		logger:          logger,
+	}
+}
+
+// Go registers a worker that runs until its context is cancelled. A worker
+// that returns before that stops the process.
+func (m *Manager) Go(name string, run func(ctx context.Context) error) {
+	m.workers = append(m.workers, worker{name: name, run: run})
+}
+
+// OnShutdown registers fn to run once all workers have stopped.
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// Run starts the workers and blocks until the process has shut down. It
+// returns the errors of the workers and shutdown hooks that failed.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, w := range m.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.run(ctx)
+			if err != nil {
+				m.logger.Error("worker failed", map[string]interface{}{
+					"worker": w.name,
+					"error":  err,
+				})
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", w.name, err))
+				mu.Unlock()
+			} else if ctx.Err() == nil {
+				m.logger.Warn("worker stopped before shutdown", map[string]interface{}{
+					"worker": w.name,
+				})
+			}
+			cancel()
+		}()
+	}
+
+	<-ctx.Done()
+	m.logger.Info("shutting down", map[string]interface{}{
+		"timeout": m.shutdownTimeout.String(),
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(m.shutdownTimeout):
+		m.logger.Warn("workers did not stop within the shutdown timeout", nil)
+		mu.Lock()
+		errs = append(errs, errors.New("workers did not stop within the shutdown timeout"))
+		mu.Unlock()
+	}
+
+	hookCtx, cancelHooks := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancelHooks()
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		err := m.hooks[i].fn(hookCtx)
+		if err != nil {
+			m.logger.Error("shutdown hook failed", map[string]interface{}{
+				"hook":  m.hooks[i].name,
+				"error": err,
+			})
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("%s: %w", m.hooks[i].name, err))
+			mu.Unlock()
+		}
+	}
+
+	m.logger.Info("shut down", nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	return errors.Join(errs...)
+}
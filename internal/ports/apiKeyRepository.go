@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type APIKeyRepository interface {
+	// CreateAPIKey creates a new API key
+	CreateAPIKey(ctx context.Context, apiKey *domain.APIKey) error
+	// GetAPIKeyByHash retrieves the API key with the given key hash
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	// ListAPIKeys retrieves all API keys, oldest first
+	ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error)
+	// DeleteAPIKey deletes an API key, which can then no longer be used
+	DeleteAPIKey(ctx context.Context, id uuid.UUID) error
+}
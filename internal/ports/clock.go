@@ -0,0 +1,13 @@
+package ports
+
+import "time"
+
+// Clock tells the time, so that the passing of time can be controlled where
+// it matters.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After waits for d to pass on the clock and then sends the current time
+	// on the returned channel
+	After(d time.Duration) <-chan time.Time
+}
@@ -0,0 +1,27 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type CustomerRepository interface {
+	// CreateCustomer creates a new customer
+	CreateCustomer(ctx context.Context, customer *domain.Customer) error
+	// GetCustomerById retrieves a customer by ID
+	GetCustomerById(ctx context.Context, id uuid.UUID) (*domain.Customer, error)
+	// GetCustomerByEmail retrieves a customer by normalized email
+	GetCustomerByEmail(ctx context.Context, email string) (*domain.Customer, error)
+	// CreateAddress creates a new address
+	CreateAddress(ctx context.Context, address *domain.Address) error
+	// UpdateAddress updates an address
+	UpdateAddress(ctx context.Context, address *domain.Address) error
+	// GetAddressById retrieves an address by ID
+	GetAddressById(ctx context.Context, id uuid.UUID) (*domain.Address, error)
+	// GetAddressesByCustomerId retrieves all addresses of a customer, oldest first
+	GetAddressesByCustomerId(ctx context.Context, customerID uuid.UUID) ([]*domain.Address, error)
+	// DeleteAddress deletes an address
+	DeleteAddress(ctx context.Context, id uuid.UUID) error
+}
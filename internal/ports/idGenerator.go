@@ -0,0 +1,9 @@
+package ports
+
+import "github.com/google/uuid"
+
+// IDGenerator hands out the IDs of new entities.
+type IDGenerator interface {
+	// NewID returns an ID that has not been handed out before
+	NewID() uuid.UUID
+}
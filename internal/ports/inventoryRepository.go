@@ -0,0 +1,39 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type InventoryRepository interface {
+	// SetStockLevel creates or replaces the stock level of a product
+	SetStockLevel(ctx context.Context, stockLevel *domain.StockLevel) error
+	// DeleteStockLevel stops tracking stock for a product
+	DeleteStockLevel(ctx context.Context, productID uuid.UUID) error
+	// GetStockLevels retrieves the stock levels of the given products that are tracked
+	GetStockLevels(ctx context.Context, productIDs []uuid.UUID) ([]domain.StockLevel, error)
+	// GetReservedQuantities sums the reservations per product that are active at the given time
+	GetReservedQuantities(ctx context.Context, productIDs []uuid.UUID, at time.Time) (map[uuid.UUID]int, error)
+	// ReserveStock replaces the reservations of an order line, all or nothing. Stock held by
+	// other active reservations is not available. Untracked products are not reserved.
+	ReserveStock(ctx context.Context, orderLineID uuid.UUID, reservations []*domain.StockReservation, at time.Time) error
+	// ExtendReservationsByOrderId moves the expiry of the reservations of an order that are
+	// active at the given time. Lapsed reservations stay lapsed.
+	ExtendReservationsByOrderId(ctx context.Context, orderID uuid.UUID, at time.Time, expires time.Time) error
+	// GetReservedOrderLineIds retrieves the lines of an order that have reservations active at the given time
+	GetReservedOrderLineIds(ctx context.Context, orderID uuid.UUID, at time.Time) ([]uuid.UUID, error)
+	// DeleteReservationsByOrderLineId releases the reservations of an order line
+	DeleteReservationsByOrderLineId(ctx context.Context, orderLineID uuid.UUID) error
+	// DeleteReservationsByOrderId releases the reservations of an order
+	DeleteReservationsByOrderId(ctx context.Context, orderID uuid.UUID) error
+	// DeleteExpiredReservations releases all reservations that expired before the given time
+	DeleteExpiredReservations(ctx context.Context, before time.Time) (int64, error)
+	// ConsumeStock releases the reservations of an order and takes the given quantities per
+	// product from stock, all or nothing. Untracked products are ignored.
+	ConsumeStock(ctx context.Context, orderID uuid.UUID, quantities map[uuid.UUID]int, at time.Time) error
+	// ReleaseStock puts the given quantities per product back into stock at the given time
+	ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int, at time.Time) error
+}
@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type JobRunRepository interface {
+	// CreateJobRun records a finished job run
+	CreateJobRun(ctx context.Context, run *domain.JobRun) error
+	// ListJobRuns retrieves a page of the runs of a job, newest first, and the total number of runs
+	ListJobRuns(ctx context.Context, jobName string, page domain.Page) ([]*domain.JobRun, int64, error)
+}
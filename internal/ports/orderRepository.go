@@ -1,23 +1,43 @@
 package ports
 
 import (
+	"context"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
 )
 
 type OrderRepository interface {
-	CreateOrder(order *domain.Order) (*domain.Order, error)
-	UpdateOrder(order *domain.Order) error
-	GetOrderById(id uuid.UUID) (*domain.Order, error)
-	DeleteOrder(id uuid.UUID) error
-	CreateOrderLine(orderLine *domain.OrderLine) (*domain.OrderLine, error)
-	UpdateOrderLine(orderLine *domain.OrderLine) error
-	GetOrderLineById(id uuid.UUID) (*domain.OrderLine, error)
-	DeleteOrderLine(id uuid.UUID) error
-	CreateOrderLineContentLine(contentLine *domain.OrderLineContentLine) (*domain.OrderLineContentLine, error)
-	DeleteOrderLineContentLine(id uuid.UUID) error
-	GetOrderBySessionId(sessionId string) (*domain.Order, error)
-	GetOrderLinesByOrderId(orderId uuid.UUID) ([]*domain.OrderLine, error)
-	GetOrderLineContentLinesByOrderLineId(orderLineId uuid.UUID) ([]*domain.OrderLineContentLine, error)
-	GetOrderByStatus(status string) ([]*domain.Order, error)
+	CreateOrder(ctx context.Context, order *domain.Order) (*domain.Order, error)
+	UpdateOrder(ctx context.Context, order *domain.Order) error
+	UpdateOrderLastActivity(ctx context.Context, id uuid.UUID, at time.Time) error
+	GetOrderById(ctx context.Context, id uuid.UUID) (*domain.Order, error)
+	// DeleteOrder deletes an order with its lines, payments and stock reservations
+	DeleteOrder(ctx context.Context, id uuid.UUID) error
+	// DeleteCreatedOrders deletes those of the given orders that are still in created status, with
+	// their lines and stock reservations, and returns the IDs of the deleted orders
+	DeleteCreatedOrders(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
+	// DeleteCreatedOrdersBefore deletes up to limit of the orders in created status last active before
+	// the given time, longest inactive first, except the excluded ones, and returns the IDs of the
+	// deleted orders
+	DeleteCreatedOrdersBefore(ctx context.Context, before time.Time, limit int, exclude []uuid.UUID) ([]uuid.UUID, error)
+	// GetCreatedOrderIdsBefore returns the orders DeleteCreatedOrdersBefore would delete
+	GetCreatedOrderIdsBefore(ctx context.Context, before time.Time, limit int, exclude []uuid.UUID) ([]uuid.UUID, error)
+	DeleteOrphanedOrderRows(ctx context.Context) (*domain.OrphanedOrderRows, error)
+	CreateOrderLine(ctx context.Context, orderLine *domain.OrderLine) (*domain.OrderLine, error)
+	UpdateOrderLine(ctx context.Context, orderLine *domain.OrderLine) error
+	GetOrderLineById(ctx context.Context, id uuid.UUID) (*domain.OrderLine, error)
+	DeleteOrderLine(ctx context.Context, id uuid.UUID) error
+	CreateOrderLineContentLine(ctx context.Context, contentLine *domain.OrderLineContentLine) (*domain.OrderLineContentLine, error)
+	UpdateOrderLineContentLine(ctx context.Context, contentLine *domain.OrderLineContentLine) error
+	DeleteOrderLineContentLine(ctx context.Context, id uuid.UUID) error
+	GetOrderBySessionId(ctx context.Context, sessionId string) (*domain.Order, error)
+	GetOrderLinesByOrderId(ctx context.Context, orderId uuid.UUID) ([]*domain.OrderLine, error)
+	GetOrderLineContentLinesByOrderLineId(ctx context.Context, orderLineId uuid.UUID) ([]*domain.OrderLineContentLine, error)
+	GetOrderLineContentLinesByOrderLineIds(ctx context.Context, orderLineIds []uuid.UUID) ([]*domain.OrderLineContentLine, error)
+	ListOrders(ctx context.Context, query domain.OrderQuery) ([]*domain.Order, int64, error)
+	CreateOrderStatusTransition(ctx context.Context, transition *domain.OrderStatusTransition) error
+	GetOrderStatusTransitionsByOrderId(ctx context.Context, orderId uuid.UUID) ([]*domain.OrderStatusTransition, error)
+	NextOrderNumber(ctx context.Context, orderId uuid.UUID) (int64, error)
 }
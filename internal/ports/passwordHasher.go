@@ -0,0 +1,9 @@
+package ports
+
+// PasswordHasher hashes passwords so that they never have to be stored.
+type PasswordHasher interface {
+	// Hash returns a salted hash of password
+	Hash(password string) (string, error)
+	// Compare returns domain.ErrInvalidCredentials unless password matches hash
+	Compare(hash string, password string) error
+}
@@ -0,0 +1,25 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type PaymentProvider interface {
+	// Name returns the name the provider is registered under, e.g. "swish"
+	Name() string
+	// CreatePaymentIntent starts a payment of amount for the order
+	CreatePaymentIntent(ctx context.Context, order *domain.Order, amount int, currency string) (*domain.PaymentIntent, error)
+	// Capture captures an authorized payment. Repeated calls with the same
+	// idempotency key capture it once.
+	Capture(ctx context.Context, reference string, amount int, idempotencyKey string) error
+	// Void releases an authorized payment without capturing it. Repeated calls
+	// with the same idempotency key void it once.
+	Void(ctx context.Context, reference string, idempotencyKey string) error
+	// Refund refunds a captured payment. Repeated calls with the same
+	// idempotency key refund it once.
+	Refund(ctx context.Context, reference string, amount int, idempotencyKey string) error
+	// HandleCallback verifies and parses a callback sent by the provider
+	HandleCallback(ctx context.Context, body []byte, headers map[string]string) (*domain.PaymentCallback, error)
+}
@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
+)
+
+type PaymentRepository interface {
+	// CreatePayment creates a new payment
+	CreatePayment(ctx context.Context, payment *domain.Payment) error
+	// UpdatePayment updates a payment
+	UpdatePayment(ctx context.Context, payment *domain.Payment) error
+	// GetPaymentByProviderReference retrieves a payment by the reference the provider gave it
+	GetPaymentByProviderReference(ctx context.Context, provider string, reference string) (*domain.Payment, error)
+	// GetPaymentsByOrderId retrieves all payments of an order, oldest first
+	GetPaymentsByOrderId(ctx context.Context, orderId uuid.UUID) ([]*domain.Payment, error)
+}
@@ -1,33 +1,43 @@
 package ports
 
 import (
+	"context"
+
 	"github.com/google/uuid"
 	"github.com/morgansundqvist/service-composable-commerce/internal/domain"
 )
 
 type ProductRepository interface {
 	// CreateProduct creates a new product
-	CreateProduct(product *domain.Product) error
+	CreateProduct(ctx context.Context, product *domain.Product) error
 	// UpdateProduct updates a product
-	UpdateProduct(product *domain.Product) error
+	UpdateProduct(ctx context.Context, product *domain.Product) error
 	// DeleteProduct deletes a product
-	DeleteProduct(productID uuid.UUID) error
+	DeleteProduct(ctx context.Context, productID uuid.UUID) error
 	// GetProduct retrieves a product by its ID
-	GetProduct(productID uuid.UUID) (*domain.Product, error)
-	// ListProducts retrieves all products
-	ListProducts() ([]domain.Product, error)
+	GetProduct(ctx context.Context, productID uuid.UUID) (*domain.Product, error)
+	// GetProductsByIDs retrieves all products with the given IDs
+	GetProductsByIDs(ctx context.Context, productIDs []uuid.UUID) ([]domain.Product, error)
+	// ListProducts retrieves a page of the products matching the query and the total number of matches
+	ListProducts(ctx context.Context, query domain.ProductQuery) ([]domain.Product, int64, error)
 	// CreateProductGroup creates a new product group
-	CreateProductGroup(productGroup *domain.ProductGroup) error
+	CreateProductGroup(ctx context.Context, productGroup *domain.ProductGroup) error
 	// UpdateProductGroup updates a product group
-	UpdateProductGroup(productGroup *domain.ProductGroup) error
+	UpdateProductGroup(ctx context.Context, productGroup *domain.ProductGroup) error
 	// DeleteProductGroup deletes a product group
-	DeleteProductGroup(productGroupID uuid.UUID) error
+	DeleteProductGroup(ctx context.Context, productGroupID uuid.UUID) error
 	// GetProductGroup retrieves a product group by its ID
-	GetProductGroup(productGroupID uuid.UUID) (*domain.ProductGroup, error)
-	// ListProductGroups retrieves all product groups
-	ListProductGroups() ([]domain.ProductGroup, error)
+	GetProductGroup(ctx context.Context, productGroupID uuid.UUID) (*domain.ProductGroup, error)
+	// GetProductGroupsByIDs retrieves all product groups with the given IDs
+	GetProductGroupsByIDs(ctx context.Context, productGroupIDs []uuid.UUID) ([]domain.ProductGroup, error)
+	// ListProductGroups retrieves a page of the product groups matching the query and the total number of matches
+	ListProductGroups(ctx context.Context, query domain.ProductGroupQuery) ([]domain.ProductGroup, int64, error)
 	// ListProductsByProductGroupID retrieves all products by product group ID
-	ListProductsByProductGroupID(productGroupID uuid.UUID) ([]domain.Product, error)
+	ListProductsByProductGroupID(ctx context.Context, productGroupID uuid.UUID) ([]domain.Product, error)
+	// ListProductsByProductGroupIDs retrieves all products in any of the given product groups
+	ListProductsByProductGroupIDs(ctx context.Context, productGroupIDs []uuid.UUID) ([]domain.Product, error)
+	// ListProductsByConfiguringProductGroupID retrieves all products configured by the product group
+	ListProductsByConfiguringProductGroupID(ctx context.Context, productGroupID uuid.UUID) ([]domain.Product, error)
 	// ListProductGroupsWithProducts retrieves all product groups with their products based on the specified conditions
-	ListProductGroupsWithProducts() ([]domain.ProductGroupWithProducts, error)
+	ListProductGroupsWithProducts(ctx context.Context) ([]domain.ProductGroupWithProducts, error)
 }
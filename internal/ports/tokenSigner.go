@@ -0,0 +1,12 @@
+package ports
+
+import "time"
+
+// TokenSigner signs short claims so that they can be handed to clients and
+// trusted when they are sent back.
+type TokenSigner interface {
+	// Sign returns a token for subject that is valid until expires
+	Sign(subject string, expires time.Time) (string, error)
+	// Verify returns the subject of a token that is correctly signed and has not expired
+	Verify(token string) (string, error)
+}
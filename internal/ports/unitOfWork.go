@@ -0,0 +1,20 @@
+package ports
+
+import "context"
+
+// Repositories gives access to repositories that share one transaction
+type Repositories interface {
+	// Orders returns the order repository of the transaction
+	Orders() OrderRepository
+	// Products returns the product repository of the transaction
+	Products() ProductRepository
+	// Inventory returns the inventory repository of the transaction
+	Inventory() InventoryRepository
+	// Payments returns the payment repository of the transaction
+	Payments() PaymentRepository
+}
+
+type UnitOfWork interface {
+	// Do runs fn in a transaction that is committed when fn returns nil and rolled back otherwise
+	Do(ctx context.Context, fn func(repositories Repositories) error) error
+}